@@ -0,0 +1,212 @@
+package cdc
+
+import (
+	"context"
+	"dm2mysql-migrator/database"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// keysPerQuery 每次回源查询和删除的主键数
+const keysPerQuery = 500
+
+// tableMeta 一张参与同步的表
+type tableMeta struct {
+	name      string // 配置中的表名，同时也是 MySQL 表名
+	keyCols   []string
+	mysqlCols []database.MySQLColumn
+}
+
+// Applier 把变更应用到 MySQL
+// 变更只携带主键，应用时按主键回源读取当前行: 行仍存在则 upsert，已不存在则删除。
+// 结果只取决于源表的当前状态，重复应用同一批变更是安全的
+type Applier struct {
+	dm     *database.DMConnector
+	mysql  *database.MySQLConnector
+	opts   database.InsertOptions
+	tables map[string]*tableMeta // 键为大写表名
+	warned map[string]bool       // 已提示过的未配置表
+}
+
+// ApplyStats 一批变更的应用结果
+type ApplyStats struct {
+	Changes  int
+	Upserted int64
+	Deleted  int64
+	Skipped  int // 未配置同步的表的变更数
+}
+
+// NewApplier 创建变更应用器，写入方式固定为 upsert
+func NewApplier(dm *database.DMConnector, mysql *database.MySQLConnector, opts database.InsertOptions) *Applier {
+	opts.WriteMode = database.WriteModeUpsert
+	return &Applier{
+		dm:     dm,
+		mysql:  mysql,
+		opts:   opts,
+		tables: make(map[string]*tableMeta),
+		warned: make(map[string]bool),
+	}
+}
+
+// AddTable 登记一张需要同步的表
+func (a *Applier) AddTable(name string, dmCols []database.DMColumn, mysqlCols []database.MySQLColumn) error {
+	keys := database.PrimaryKeyColumns(dmCols)
+	if len(keys) == 0 {
+		return fmt.Errorf("表 %s 没有主键，无法应用变更", name)
+	}
+	a.tables[strings.ToUpper(name)] = &tableMeta{name: name, keyCols: keys, mysqlCols: mysqlCols}
+	return nil
+}
+
+// Apply 按变更顺序逐表应用一批变更
+func (a *Applier) Apply(changes []Change) (ApplyStats, error) {
+	stats := ApplyStats{Changes: len(changes)}
+
+	// 同一主键在一批中多次变更时只需应用一次；表按第一次出现的顺序处理
+	var order []string
+	keysByTable := make(map[string][][]string)
+	seen := make(map[string]bool)
+	for _, c := range changes {
+		t := strings.ToUpper(c.Table)
+		if _, ok := a.tables[t]; !ok {
+			stats.Skipped++
+			if !a.warned[t] {
+				log.Printf("⚠️  表 %s 不在同步列表中，忽略其变更", c.Table)
+				a.warned[t] = true
+			}
+			continue
+		}
+		id := t + "\x00" + strings.Join(c.Key, "\x00")
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, ok := keysByTable[t]; !ok {
+			order = append(order, t)
+		}
+		keysByTable[t] = append(keysByTable[t], c.Key)
+	}
+
+	for _, t := range order {
+		meta := a.tables[t]
+		keys := keysByTable[t]
+		for start := 0; start < len(keys); start += keysPerQuery {
+			end := start + keysPerQuery
+			if end > len(keys) {
+				end = len(keys)
+			}
+			upserted, deleted, err := a.applyKeys(meta, keys[start:end])
+			stats.Upserted += upserted
+			stats.Deleted += deleted
+			if err != nil {
+				return stats, fmt.Errorf("表 %s: %v", meta.name, err)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// applyKeys 回源读取一组主键对应的行并同步到 MySQL
+func (a *Applier) applyKeys(meta *tableMeta, keys [][]string) (int64, int64, error) {
+	for _, k := range keys {
+		if len(k) != len(meta.keyCols) {
+			return 0, 0, fmt.Errorf("变更的主键有 %d 列，表主键有 %d 列，请重新安装触发器", len(k), len(meta.keyCols))
+		}
+	}
+
+	existing, err := a.dm.ExistingKeys(meta.name, meta.keyCols, keys)
+	if err != nil {
+		return 0, 0, fmt.Errorf("回源查询主键失败: %v", err)
+	}
+	present := make(map[string]bool, len(existing))
+	for _, k := range existing {
+		present[strings.Join(k, "\x00")] = true
+	}
+	var missing [][]string
+	for _, k := range keys {
+		if !present[strings.Join(k, "\x00")] {
+			missing = append(missing, k)
+		}
+	}
+
+	var deleted int64
+	if len(missing) > 0 {
+		deleted, err = a.mysql.DeleteByKeys(meta.name, meta.keyCols, missing)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(existing) == 0 {
+		return 0, deleted, nil
+	}
+
+	rows, err := a.dm.GetRowsByKeys(meta.name, database.ColumnNames(meta.mysqlCols), meta.keyCols, existing)
+	if err != nil {
+		return 0, deleted, fmt.Errorf("回源读取失败: %v", err)
+	}
+	defer rows.Close()
+	stats, err := a.mysql.BatchInsertData(meta.name, meta.mysqlCols, rows, a.opts)
+	return stats.Rows, deleted, err
+}
+
+// PositionStore 保存已应用位置
+type PositionStore interface {
+	SetPosition(source, position string) error
+}
+
+// RunOptions 消费循环参数
+type RunOptions struct {
+	Interval  time.Duration // 没有新变更或出错后的等待时间
+	BatchSize int           // 每次读取的最大变更数
+}
+
+// Run 持续读取并应用变更，直到 ctx 被取消
+// 每批变更先应用到 MySQL，再记录已应用位置，最后通知来源清理；任何一步失败都会在等待后重试这一批
+func Run(ctx context.Context, src Source, ap *Applier, positions PositionStore, opts RunOptions) error {
+	log.Printf("🔄 开始消费 %s 变更 (每批最多 %d 条, 轮询间隔 %v)", src.Name(), opts.BatchSize, opts.Interval)
+	for {
+		n, err := runOnce(ctx, src, ap, positions, opts)
+		if err != nil {
+			log.Printf("⚠️  应用变更失败，%v 后重试: %v", opts.Interval, err)
+		}
+		if err == nil && n > 0 && ctx.Err() == nil {
+			// 还有积压时不等待，直接读取下一批
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// runOnce 处理一批变更，返回处理的变更数
+func runOnce(ctx context.Context, src Source, ap *Applier, positions PositionStore, opts RunOptions) (int, error) {
+	changes, err := src.Poll(ctx, opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("读取变更失败: %v", err)
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	stats, err := ap.Apply(changes)
+	if err != nil {
+		return 0, err
+	}
+	last := changes[len(changes)-1].Position
+	if err := positions.SetPosition(src.Name(), last); err != nil {
+		return 0, fmt.Errorf("保存已应用位置失败: %v", err)
+	}
+	if err := src.Ack(changes); err != nil {
+		return 0, fmt.Errorf("清理已消费变更失败: %v", err)
+	}
+	log.Printf("📦 应用 %d 条变更 (upsert %d 行, 删除 %d 行, 忽略 %d 条), 已应用到 %s, 耗时 %v",
+		stats.Changes, stats.Upserted, stats.Deleted, stats.Skipped, last, time.Since(start).Round(time.Millisecond))
+	return len(changes), nil
+}
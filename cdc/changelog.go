@@ -0,0 +1,55 @@
+package cdc
+
+import (
+	"context"
+	"dm2mysql-migrator/database"
+	"strconv"
+)
+
+// changeLogSource 读取触发器写入的变更日志表
+type changeLogSource struct {
+	dm *database.DMConnector
+}
+
+// NewChangeLogSource 创建基于触发器变更日志的来源
+func NewChangeLogSource(dm *database.DMConnector) Source {
+	return &changeLogSource{dm: dm}
+}
+
+func (s *changeLogSource) Name() string {
+	return "changelog"
+}
+
+func (s *changeLogSource) Poll(ctx context.Context, limit int) ([]Change, error) {
+	entries, err := s.dm.ReadChangeLog(limit)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]Change, len(entries))
+	for i, e := range entries {
+		changes[i] = Change{
+			Table:    e.Table,
+			Op:       Op(e.Op),
+			Key:      e.Keys,
+			Position: strconv.FormatInt(e.ID, 10),
+		}
+	}
+	return changes, nil
+}
+
+// Ack 删除已应用的变更日志
+func (s *changeLogSource) Ack(changes []Change) error {
+	ids := make([]int64, 0, len(changes))
+	for _, c := range changes {
+		id, err := strconv.ParseInt(c.Position, 10, 64)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	return s.dm.PurgeChangeLog(ids)
+}
+
+func (s *changeLogSource) Close() error {
+	return nil
+}
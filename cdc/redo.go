@@ -0,0 +1,405 @@
+package cdc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// redoValue redo SQL 中的一个值
+type redoValue struct {
+	text  string // 字面量的内容，字符串已去掉引号
+	null  bool
+	plain bool // 是否为数字或字符串字面量；TIMESTAMP'...'、函数调用等为 false
+}
+
+// redoStmt 解析后的一条 redo SQL
+type redoStmt struct {
+	op    Op
+	table string               // 不含模式名
+	set   map[string]redoValue // INSERT 的列值或 UPDATE 的 SET 部分，键为列名
+	where map[string]redoValue // UPDATE / DELETE 的 WHERE 部分，键为列名
+}
+
+// parseRedo 解析 LogMiner 生成的单行 DML:
+//
+//	INSERT INTO "S"."T"("A", "B") VALUES(1, 'x');
+//	UPDATE "S"."T" SET "B" = 'y' WHERE "A" = 1 AND "B" = 'x';
+//	DELETE FROM "S"."T" WHERE "A" = 1 AND "B" IS NULL;
+func parseRedo(sql string) (*redoStmt, error) {
+	p := &redoParser{toks: tokenizeRedo(sql)}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, fmt.Errorf("无法解析 redo SQL %q: %v", sql, err)
+	}
+	return stmt, nil
+}
+
+type redoToken struct {
+	kind byte // i 标识符, q 双引号标识符, s 字符串, n 数字, p 标点
+	text string
+}
+
+// tokenizeRedo 把 SQL 切分为标识符、字符串、数字和标点，双引号标识符保留原样大小写
+func tokenizeRedo(sql string) []redoToken {
+	var toks []redoToken
+	rs := []rune(sql)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			var b strings.Builder
+			for j < len(rs) {
+				if rs[j] == '"' {
+					if j+1 < len(rs) && rs[j+1] == '"' {
+						b.WriteRune('"')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(rs[j])
+				j++
+			}
+			toks = append(toks, redoToken{'q', b.String()})
+			i = j + 1
+		case c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for j < len(rs) {
+				if rs[j] == '\'' {
+					if j+1 < len(rs) && rs[j+1] == '\'' {
+						b.WriteRune('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(rs[j])
+				j++
+			}
+			toks = append(toks, redoToken{'s', b.String()})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' || c == '.') && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'E' || rs[j] == 'e' ||
+				(rs[j] == '-' || rs[j] == '+') && (rs[j-1] == 'E' || rs[j-1] == 'e')) {
+				j++
+			}
+			toks = append(toks, redoToken{'n', string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '$' || rs[j] == '#') {
+				j++
+			}
+			// 不带引号的标识符按达梦的规则折叠为大写，与数据字典中的名字一致
+			toks = append(toks, redoToken{'i', strings.ToUpper(string(rs[i:j]))})
+			i = j
+		default:
+			toks = append(toks, redoToken{'p', string(c)})
+			i++
+		}
+	}
+	return toks
+}
+
+type redoParser struct {
+	toks []redoToken
+	pos  int
+}
+
+func (p *redoParser) peek() redoToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return redoToken{}
+}
+
+func (p *redoParser) next() redoToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// keyword 消费一个关键字，关键字不区分大小写，双引号标识符不会被当作关键字
+func (p *redoParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == 'i' && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *redoParser) punct(s string) bool {
+	if t := p.peek(); t.kind == 'p' && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *redoParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return fmt.Errorf("应为 %s，实际为 %q", kw, p.peek().text)
+	}
+	return nil
+}
+
+func (p *redoParser) expectPunct(s string) error {
+	if !p.punct(s) {
+		return fmt.Errorf("应为 %s，实际为 %q", s, p.peek().text)
+	}
+	return nil
+}
+
+// ident 消费一个标识符，双引号标识符保持原样大小写
+func (p *redoParser) ident() (string, error) {
+	t := p.next()
+	if t.kind != 'i' && t.kind != 'q' {
+		return "", fmt.Errorf("应为标识符，实际为 %q", t.text)
+	}
+	return t.text, nil
+}
+
+// tableName 解析 [模式.]表名，返回表名
+func (p *redoParser) tableName() (string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", err
+	}
+	for p.punct(".") {
+		if name, err = p.ident(); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+func (p *redoParser) statement() (*redoStmt, error) {
+	switch {
+	case p.keyword("INSERT"):
+		return p.insert()
+	case p.keyword("UPDATE"):
+		return p.update()
+	case p.keyword("DELETE"):
+		return p.delete()
+	}
+	return nil, fmt.Errorf("不支持的语句 %q", p.peek().text)
+}
+
+func (p *redoParser) insert() (*redoStmt, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var cols []string
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+		if !p.punct(",") {
+			break
+		}
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	stmt := &redoStmt{op: OpInsert, table: table, set: make(map[string]redoValue)}
+	for i, col := range cols {
+		if i > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		stmt.set[col] = v
+	}
+	return stmt, p.expectPunct(")")
+}
+
+func (p *redoParser) update() (*redoStmt, error) {
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	stmt := &redoStmt{op: OpUpdate, table: table, set: make(map[string]redoValue)}
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("="); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		stmt.set[col] = v
+		if !p.punct(",") {
+			break
+		}
+	}
+	stmt.where, err = p.where()
+	return stmt, err
+}
+
+func (p *redoParser) delete() (*redoStmt, error) {
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	table, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &redoStmt{op: OpDelete, table: table}
+	stmt.where, err = p.where()
+	return stmt, err
+}
+
+// where 解析 WHERE a = 1 AND b IS NULL 形式的条件
+func (p *redoParser) where() (map[string]redoValue, error) {
+	if err := p.expectKeyword("WHERE"); err != nil {
+		return nil, err
+	}
+	conds := make(map[string]redoValue)
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		var v redoValue
+		if p.keyword("IS") {
+			if err := p.expectKeyword("NULL"); err != nil {
+				return nil, err
+			}
+			v = redoValue{null: true}
+		} else {
+			if err := p.expectPunct("="); err != nil {
+				return nil, err
+			}
+			if v, err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		conds[col] = v
+		if !p.keyword("AND") {
+			break
+		}
+	}
+	return conds, nil
+}
+
+// value 解析一个值: 数字、字符串、NULL、TIMESTAMP'...' 这类带类型的字面量或函数调用
+func (p *redoParser) value() (redoValue, error) {
+	t := p.next()
+	switch t.kind {
+	case 'n', 's':
+		return redoValue{text: t.text, plain: true}, nil
+	case 'i':
+		if t.text == "NULL" {
+			return redoValue{null: true}, nil
+		}
+		if n := p.peek(); n.kind == 's' {
+			p.pos++
+			return redoValue{text: n.text}, nil
+		}
+		if p.punct("(") {
+			start := p.pos - 2
+			for depth := 1; depth > 0; {
+				n := p.next()
+				switch {
+				case n.kind == 0:
+					return redoValue{}, fmt.Errorf("函数调用 %s 缺少右括号", t.text)
+				case n.kind == 'p' && n.text == "(":
+					depth++
+				case n.kind == 'p' && n.text == ")":
+					depth--
+				}
+			}
+			var parts []string
+			for _, tok := range p.toks[start:p.pos] {
+				parts = append(parts, tok.text)
+			}
+			return redoValue{text: strings.Join(parts, "")}, nil
+		}
+	}
+	return redoValue{}, fmt.Errorf("无法识别的值 %q", t.text)
+}
+
+// changeKeys 按主键列从解析结果中取出受影响行的主键
+// 修改主键的 UPDATE 返回旧主键和新主键；主键值不是数字或字符串字面量时报错，
+// 因为无法保证与回源查询时的字符串形式一致
+func (s *redoStmt) changeKeys(keyCols []string) ([][]string, error) {
+	pick := func(vals map[string]redoValue, fallback []string) ([]string, error) {
+		key := make([]string, len(keyCols))
+		for i, col := range keyCols {
+			// 主键列名来自数据字典，与 redo SQL 中的列名按原样比较，"MixedCase" 与 MIXEDCASE 是不同的列
+			v, ok := vals[col]
+			if !ok {
+				if fallback == nil {
+					return nil, fmt.Errorf("redo SQL 中缺少主键列 %s", col)
+				}
+				key[i] = fallback[i]
+				continue
+			}
+			if v.null || !v.plain {
+				return nil, fmt.Errorf("主键列 %s 的值不是数字或字符串字面量，不支持通过日志解析", col)
+			}
+			key[i] = v.text
+		}
+		return key, nil
+	}
+
+	switch s.op {
+	case OpInsert:
+		key, err := pick(s.set, nil)
+		if err != nil {
+			return nil, err
+		}
+		return [][]string{key}, nil
+	case OpDelete:
+		key, err := pick(s.where, nil)
+		if err != nil {
+			return nil, err
+		}
+		return [][]string{key}, nil
+	}
+
+	old, err := pick(s.where, nil)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := pick(s.set, old)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(cur, "\x00") == strings.Join(old, "\x00") {
+		return [][]string{old}, nil
+	}
+	return [][]string{old, cur}, nil
+}
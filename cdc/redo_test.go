@@ -0,0 +1,78 @@
+package cdc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRedo(t *testing.T) {
+	tests := []struct {
+		sql     string
+		op      Op
+		table   string
+		set     map[string]redoValue
+		where   map[string]redoValue
+		wantErr bool
+	}{
+		{
+			sql:   `INSERT INTO "S"."T"("A", "b") VALUES(1, 'x');`,
+			op:    OpInsert,
+			table: "T",
+			set:   map[string]redoValue{"A": {text: "1", plain: true}, "b": {text: "x", plain: true}},
+		},
+		{
+			// 双引号标识符保持原样大小写，不带引号的折叠为大写
+			sql:   `UPDATE "S"."MixedCase" SET "MixedCase" = 1, plain_col = 2 WHERE "Id" = 3 AND id = 4;`,
+			op:    OpUpdate,
+			table: "MixedCase",
+			set:   map[string]redoValue{"MixedCase": {text: "1", plain: true}, "PLAIN_COL": {text: "2", plain: true}},
+			where: map[string]redoValue{"Id": {text: "3", plain: true}, "ID": {text: "4", plain: true}},
+		},
+		{
+			// 双引号中的关键字是列名
+			sql:   `INSERT INTO "S"."T"("WHERE", "NULL") VALUES(1, NULL);`,
+			op:    OpInsert,
+			table: "T",
+			set:   map[string]redoValue{"WHERE": {text: "1", plain: true}, "NULL": {null: true}},
+		},
+		{
+			sql:   `UPDATE "S"."My ""T""" SET "B" = 'it''s' WHERE "A" = -1.5E3 AND "C" IS NULL;`,
+			op:    OpUpdate,
+			table: `My "T"`,
+			set:   map[string]redoValue{"B": {text: "it's", plain: true}},
+			where: map[string]redoValue{"A": {text: "-1.5E3", plain: true}, "C": {null: true}},
+		},
+		{
+			sql:   `DELETE FROM "S"."T" WHERE "A" = 1 AND "D" = TIMESTAMP'2024-01-01 00:00:00' AND "E" = HEXTORAW('FF');`,
+			op:    OpDelete,
+			table: "T",
+			where: map[string]redoValue{
+				"A": {text: "1", plain: true},
+				"D": {text: "2024-01-01 00:00:00"},
+				"E": {text: "HEXTORAW(FF)"}, // 函数调用只保留文本用于报错，不能作为主键
+			},
+		},
+		{sql: `MERGE INTO "S"."T" USING x`, wantErr: true},
+		{sql: `INSERT INTO "S"."T"("A") VALUES(1`, wantErr: true},
+		{sql: `DELETE FROM "S"."T" WHERE "A" = HEXTORAW('FF'`, wantErr: true},
+	}
+	for _, tt := range tests {
+		stmt, err := parseRedo(tt.sql)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRedo(%q) error = %v, wantErr %v", tt.sql, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if stmt.op != tt.op || stmt.table != tt.table {
+			t.Errorf("parseRedo(%q) = %s %q, want %s %q", tt.sql, stmt.op, stmt.table, tt.op, tt.table)
+		}
+		if tt.set != nil && !reflect.DeepEqual(stmt.set, tt.set) {
+			t.Errorf("parseRedo(%q) set = %+v, want %+v", tt.sql, stmt.set, tt.set)
+		}
+		if tt.where != nil && !reflect.DeepEqual(stmt.where, tt.where) {
+			t.Errorf("parseRedo(%q) where = %+v, want %+v", tt.sql, stmt.where, tt.where)
+		}
+	}
+}
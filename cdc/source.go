@@ -0,0 +1,34 @@
+package cdc
+
+import (
+	"context"
+)
+
+// Op 变更类型
+type Op string
+
+const (
+	OpInsert Op = "I"
+	OpUpdate Op = "U"
+	OpDelete Op = "D"
+)
+
+// Change 源库的一条行变更，只携带主键，行内容由消费端按主键回源读取
+type Change struct {
+	Table    string
+	Op       Op
+	Key      []string // 主键值的字符串形式，顺序与主键列一致
+	Position string   // 该变更在源中的位置，消费完成后记录为已应用位置
+}
+
+// Source 变更来源
+type Source interface {
+	// Name 返回来源名称，用作状态文件中已应用位置的键
+	Name() string
+	// Poll 按提交顺序返回最多 limit 条尚未确认的变更，没有新变更时返回空
+	Poll(ctx context.Context, limit int) ([]Change, error)
+	// Ack 确认变更已应用到 MySQL，来源可以清理或越过这些变更
+	Ack(changes []Change) error
+	// Close 释放来源持有的资源
+	Close() error
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"dm2mysql-migrator/cdc"
+	"dm2mysql-migrator/checkpoint"
+	"dm2mysql-migrator/config"
+	"dm2mysql-migrator/database"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// runCDC 执行 cdc 子命令: 安装/卸载变更捕获触发器，或持续把变更应用到 MySQL
+func runCDC(args []string) {
+	fs := flag.NewFlagSet("cdc", flag.ExitOnError)
+	dropLog := fs.Bool("drop-log", false, "uninstall 时同时删除变更日志表")
+	interval := fs.Duration("interval", time.Second, "run 时没有新变更后的轮询间隔")
+	batchChanges := fs.Int("batch-changes", 1000, "run 时每批读取的最大变更数")
+	source := fs.String("source", "changelog", "run 时的变更来源: changelog (触发器写入的变更日志), logminer (解析归档日志)")
+	startLSN := fs.Int64("start-lsn", 0, "logminer 来源首次运行时的起始 LSN，只读取此后提交的事务；未指定时使用全量迁移的快照点，状态文件中已有位置时忽略")
+	replay := fs.String("replay", "", "logminer 来源改为回放录制的 LogMiner 结果 (JSONL 文件)，不连接归档日志")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s [参数] cdc [选项] install|uninstall|run\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *dmUser == "" || *dmPass == "" || *dmSchema == "" {
+		fmt.Println("❌ 达梦参数缺失")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	tablesConfig, err := config.LoadTablesConfig(*tablesConfigFile)
+	if err != nil {
+		log.Fatalf("加载表配置文件失败: %v", err)
+	}
+	tables := tablesConfig.Tables
+
+	dmConn, err := database.NewDMConnector(buildDMDSN())
+	if err != nil {
+		log.Fatalf("达梦连接失败: %v", err)
+	}
+	defer dmConn.Close()
+
+	switch fs.Arg(0) {
+	case "install":
+		cdcInstall(dmConn, tables)
+	case "uninstall":
+		cdcUninstall(dmConn, tables, *dropLog)
+	case "run":
+		if *batchChanges <= 0 {
+			log.Fatalf("-batch-changes 必须大于 0")
+		}
+		if *source != "changelog" && *source != "logminer" {
+			log.Fatalf("未知的变更来源 %q (可选: changelog, logminer)", *source)
+		}
+		cdcRun(dmConn, tables, *source, *startLSN, *replay, cdc.RunOptions{Interval: *interval, BatchSize: *batchChanges})
+	default:
+		fmt.Printf("❌ 未知的 cdc 操作: %s\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// cdcInstall 创建变更日志表并为每张表安装触发器
+func cdcInstall(dm *database.DMConnector, tables []config.TableConfig) {
+	if err := dm.EnsureChangeLog(); err != nil {
+		log.Fatalf("创建变更日志表失败: %v", err)
+	}
+	log.Printf("✅ 变更日志表 %s 已就绪", database.ChangeLogTable)
+
+	failed := 0
+	for _, t := range tables {
+		cols, err := dm.GetTableSchema(t.Name)
+		if err == nil {
+			err = dm.InstallCDCTrigger(t.Name, cols)
+		}
+		if err != nil {
+			log.Printf("❌ 表 %s 安装触发器失败: %v", t.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("✅ 表 %s 已安装触发器 %s", t.Name, database.CDCTriggerName(t.Name))
+	}
+
+	log.Printf("🪝 安装完成: 成功 %d 张表, 失败 %d 张", len(tables)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// cdcUninstall 删除每张表的触发器，dropLog 为 true 时同时删除变更日志表
+func cdcUninstall(dm *database.DMConnector, tables []config.TableConfig, dropLog bool) {
+	failed := 0
+	for _, t := range tables {
+		if err := dm.UninstallCDCTrigger(t.Name); err != nil {
+			log.Printf("❌ 表 %s 删除触发器失败: %v", t.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("✅ 表 %s 的触发器已删除", t.Name)
+	}
+
+	if dropLog && failed == 0 {
+		if err := dm.DropChangeLog(); err != nil {
+			log.Fatalf("删除变更日志表失败: %v", err)
+		}
+		log.Printf("🗑️  变更日志表 %s 已删除", database.ChangeLogTable)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// cdcRun 持续消费变更直到收到中断信号
+func cdcRun(dm *database.DMConnector, tables []config.TableConfig, source string, startLSN int64, replay string, opts cdc.RunOptions) {
+	checkMySQLFlags()
+
+	mysqlConn, err := database.NewMySQLConnector(buildMySQLDSN(), *mysqlVer, mysqlSessionInit(false)...)
+	if err != nil {
+		log.Fatalf("MySQL连接失败: %v", err)
+	}
+	defer mysqlConn.Close()
+
+	// 只读写已应用位置，不影响迁移的表检查点
+	store, err = checkpoint.Open(*stateFile, true)
+	if err != nil {
+		log.Fatalf("打开状态文件失败: %v", err)
+	}
+
+	ap := cdc.NewApplier(dm, mysqlConn, database.InsertOptions{
+		BatchSize: *batchSize,
+		Writers:   1,
+	})
+	keys := make(map[string][]string, len(tables))
+	for _, t := range tables {
+		if len(t.Offload) > 0 {
+			log.Fatalf("表 %s 配置了外存列，变更捕获暂不支持外存列", t.Name)
+		}
+		dmCols, err := dm.GetTableSchema(t.Name)
+		if err != nil {
+			log.Fatalf("获取表 %s 结构失败: %v", t.Name, err)
+		}
+		mysqlCols := toMySQLColumns(dmCols)
+		exists, err := mysqlConn.TableExists(t.Name)
+		if err != nil {
+			log.Fatalf("检查目标表 %s 失败: %v", t.Name, err)
+		}
+		if !exists {
+			log.Fatalf("目标表 %s 不存在，请先完成全量迁移", t.Name)
+		}
+		if err := mysqlConn.CheckTableCompatible(t.Name, mysqlCols); err != nil {
+			log.Fatalf("目标表 %s 结构不兼容: %v", t.Name, err)
+		}
+		if err := ap.AddTable(t.Name, dmCols, mysqlCols); err != nil {
+			log.Fatalf("%v", err)
+		}
+		keys[t.Name] = database.PrimaryKeyColumns(dmCols)
+	}
+
+	var src cdc.Source
+	switch source {
+	case "changelog":
+		src = cdc.NewChangeLogSource(dm)
+		if pos := store.Position(src.Name()); pos != "" {
+			log.Printf("🔖 上次已应用到 %s 位置 %s", src.Name(), pos)
+		}
+	case "logminer":
+		name := "logminer"
+		var reader cdc.LogMinerReader
+		if replay == "" {
+			session := dm.NewLogMinerSession()
+			defer session.Close()
+			reader = session
+		} else {
+			name = "logminer-replay"
+			if reader, err = cdc.NewReplayReader(replay); err != nil {
+				log.Fatalf("加载回放文件失败: %v", err)
+			}
+		}
+		start := startLSN
+		if pos := store.Position(name); pos != "" {
+			if start, err = strconv.ParseInt(pos, 10, 64); err != nil {
+				log.Fatalf("状态文件中 %s 的位置 %q 无效: %v", name, pos, err)
+			}
+			log.Printf("🔖 上次已应用到 %s LSN %d", name, start)
+		} else if snap, ok := store.Snapshot(); ok && start <= 0 {
+			// 全量迁移读取的是快照点的数据，从快照点之后提交的事务继续
+			start = snap.LSN
+			log.Printf("📸 从全量迁移的快照点 LSN %d 开始", start)
+		} else if start <= 0 && replay == "" {
+			log.Fatalf("首次使用 logminer 来源需要通过 -start-lsn 指定起始 LSN (全量迁移开始前的 LSN)")
+		}
+		src = cdc.NewLogMinerSource(name, reader, start, keys)
+	}
+	defer src.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cdc.Run(ctx, src, ap, store, opts); err != nil {
+		log.Fatalf("变更同步失败: %v", err)
+	}
+	log.Println("👋 收到退出信号，变更同步已停止")
+}
@@ -0,0 +1,274 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 表的迁移状态
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+)
+
+// saveInterval 进度类更新最多每隔这么久写一次文件
+// 落盘的进度落后于实际写入是安全的: 续传时会先删除检查点之后的行再重新导入
+const saveInterval = time.Second
+
+// State 状态文件内容
+type State struct {
+	RunID      string                 `json:"run_id"`
+	Snapshot   *Snapshot              `json:"snapshot,omitempty"` // 本次运行读取的一致性快照点
+	Tables     map[string]*TableState `json:"tables"`
+	Watermarks map[string]Watermark   `json:"watermarks,omitempty"` // 增量同步的水位，跨运行保留
+	Positions  map[string]string      `json:"positions,omitempty"`  // 变更捕获各来源的已应用位置，跨运行保留
+}
+
+// Watermark 增量同步已完成到的水位
+type Watermark struct {
+	Column    string `json:"column"`
+	Kind      string `json:"kind"`  // time 或 number
+	Value     string `json:"value"` // 时间为 RFC3339 格式，整数为十进制
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Snapshot 一致性快照点，增量同步和变更捕获可从这里继续
+type Snapshot struct {
+	LSN  int64  `json:"lsn"`
+	Time string `json:"time"` // 快照点的数据库时间，RFC3339 格式
+}
+
+// TableState 单张表的检查点
+type TableState struct {
+	Status    string       `json:"status"`
+	Action    string       `json:"action,omitempty"`     // 首次导入时对目标表执行的动作，如 create / truncate / append
+	Target    string       `json:"target"`               // 实际写入的表，使用影子表时为 <表名>__new
+	Backup    string       `json:"backup,omitempty"`     // 本次运行创建的备份表
+	KeyColumn string       `json:"key_column,omitempty"` // 续传使用的整数主键列，为空表示不能按键续传
+	LastKey   *int64       `json:"last_key,omitempty"`   // 整表导入时已提交的最大键值
+	Rows      int64        `json:"rows"`                 // 已提交的行数
+	Chunks    []ChunkState `json:"chunks,omitempty"`     // 分片导入时各分片的检查点
+	UpdatedAt string       `json:"updated_at"`
+}
+
+// ChunkState 单个分片的检查点
+type ChunkState struct {
+	Lo      int64  `json:"lo"`
+	Hi      int64  `json:"hi"`
+	LastKey *int64 `json:"last_key,omitempty"` // 分片内已提交的最大键值
+	Rows    int64  `json:"rows"`
+	Done    bool   `json:"done"`
+}
+
+// Store 检查点存储，所有修改都会写回本地 JSON 状态文件
+type Store struct {
+	mu       sync.Mutex
+	path     string
+	state    State
+	dirty    bool
+	lastSave time.Time
+}
+
+// Open 打开状态文件
+// resume 为 false 时丢弃上次运行的表检查点，从空状态开始，只保留增量同步的水位和变更捕获位置
+func Open(path string, resume bool) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("读取状态文件失败: %v", err)
+	default:
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("解析状态文件失败: %v", err)
+		}
+	}
+	if !resume {
+		s.state = State{Watermarks: s.state.Watermarks, Positions: s.state.Positions}
+	}
+	if s.state.Tables == nil {
+		s.state.Tables = make(map[string]*TableState)
+	}
+	if s.state.Watermarks == nil {
+		s.state.Watermarks = make(map[string]Watermark)
+	}
+	if s.state.Positions == nil {
+		s.state.Positions = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Path 返回状态文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// RunID 返回状态文件记录的运行 ID
+func (s *Store) RunID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.RunID
+}
+
+// SetRunID 记录本次运行 ID 并立即保存
+func (s *Store) SetRunID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.RunID = id
+	return s.save()
+}
+
+// Snapshot 返回状态文件记录的快照点
+func (s *Store) Snapshot() (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state.Snapshot == nil {
+		return Snapshot{}, false
+	}
+	return *s.state.Snapshot, true
+}
+
+// SetSnapshot 记录本次运行的快照点并立即保存
+func (s *Store) SetSnapshot(snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Snapshot = &snap
+	return s.save()
+}
+
+// Table 返回表检查点的副本
+func (s *Store) Table(name string) (TableState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.state.Tables[name]
+	if !ok {
+		return TableState{}, false
+	}
+	cp := *st
+	cp.Chunks = append([]ChunkState(nil), st.Chunks...)
+	return cp, true
+}
+
+// Update 修改表检查点并立即保存，用于状态变化
+func (s *Store) Update(name string, fn func(*TableState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apply(name, fn)
+	return s.save()
+}
+
+// Progress 修改表检查点，距上次保存不足 saveInterval 时只记在内存中，用于频繁的进度更新
+func (s *Store) Progress(name string, fn func(*TableState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apply(name, fn)
+	if time.Since(s.lastSave) < saveInterval {
+		return nil
+	}
+	return s.save()
+}
+
+// Reset 删除表检查点，下次运行该表将从头导入
+func (s *Store) Reset(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state.Tables, name)
+	s.dirty = true
+	return s.save()
+}
+
+// Watermark 返回表的增量同步水位
+func (s *Store) Watermark(name string) (Watermark, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.state.Watermarks[name]
+	return w, ok
+}
+
+// SetWatermark 记录表的增量同步水位并立即保存
+func (s *Store) SetWatermark(name string, w Watermark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.state.Watermarks[name] = w
+	return s.save()
+}
+
+// Position 返回变更来源的已应用位置
+func (s *Store) Position(source string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Positions[source]
+}
+
+// SetPosition 记录变更来源的已应用位置并立即保存
+func (s *Store) SetPosition(source, position string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Positions[source] = position
+	return s.save()
+}
+
+// Flush 保存尚未落盘的修改
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *Store) apply(name string, fn func(*TableState)) {
+	st, ok := s.state.Tables[name]
+	if !ok {
+		st = &TableState{}
+		s.state.Tables[name] = st
+	}
+	fn(st)
+	st.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.dirty = true
+}
+
+// save 先写临时文件再重命名，进程在写入途中退出也不会留下损坏的状态文件
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	s.lastSave = time.Now()
+	return nil
+}
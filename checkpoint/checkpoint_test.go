@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "migrate.json")
+	s, err := Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetRunID("20240101-000000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSnapshot(Snapshot{LSN: 123, Time: "2024-01-01T00:00:00Z"}); err != nil {
+		t.Fatal(err)
+	}
+	last := int64(500)
+	chunkLast := int64(1500)
+	if err := s.Update("ORDERS", func(st *TableState) {
+		st.Status = StatusInProgress
+		st.Action = "create"
+		st.Target = "ORDERS__new"
+		st.KeyColumn = "ID"
+		st.LastKey = &last
+		st.Rows = 500
+		st.Chunks = []ChunkState{{Lo: 1, Hi: 1000, Done: true, Rows: 1000}, {Lo: 1001, Hi: 2000, LastKey: &chunkLast, Rows: 500}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 进度更新可能只记在内存中，Flush 后落盘
+	if err := s.Progress("ORDERS", func(st *TableState) { st.Rows = 600 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetWatermark("EVENTS", Watermark{Column: "ID", Kind: "number", Value: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPosition("logminer", "9001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.RunID(); got != "20240101-000000" {
+		t.Errorf("RunID = %q", got)
+	}
+	if snap, ok := r.Snapshot(); !ok || snap.LSN != 123 {
+		t.Errorf("Snapshot = %+v, %v", snap, ok)
+	}
+	want, _ := s.Table("ORDERS")
+	got, ok := r.Table("ORDERS")
+	if !ok || !reflect.DeepEqual(got, want) || got.Rows != 600 || *got.LastKey != 500 || *got.Chunks[1].LastKey != 1500 {
+		t.Errorf("Table = %+v, want %+v", got, want)
+	}
+	if w, ok := r.Watermark("EVENTS"); !ok || w.Value != "42" || w.UpdatedAt == "" {
+		t.Errorf("Watermark = %+v, %v", w, ok)
+	}
+	if pos := r.Position("logminer"); pos != "9001" {
+		t.Errorf("Position = %q", pos)
+	}
+
+	// 不续传时丢弃表检查点，保留水位和变更捕获位置
+	n, err := Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.Table("ORDERS"); ok {
+		t.Error("table checkpoint kept without resume")
+	}
+	if _, ok := n.Snapshot(); ok {
+		t.Error("snapshot kept without resume")
+	}
+	if _, ok := n.Watermark("EVENTS"); !ok || n.Position("logminer") != "9001" {
+		t.Error("watermark or position lost without resume")
+	}
+
+	// Reset 后该表从头导入
+	if err := r.Reset("ORDERS"); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Open(path, true); again != nil {
+		if _, ok := again.Table("ORDERS"); ok {
+			t.Error("table checkpoint kept after Reset")
+		}
+	}
+}
+
+func TestStoreTableCopy(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "migrate.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Update("T", func(st *TableState) { st.Chunks = []ChunkState{{Lo: 1, Hi: 10}} })
+	cp, _ := s.Table("T")
+	cp.Chunks[0].Done = true
+	if st, _ := s.Table("T"); st.Chunks[0].Done {
+		t.Error("Table returned shared chunk slice")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"dm2mysql-migrator/config"
+	"dm2mysql-migrator/database"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// tableChunks 返回表的分片数，表级配置优先于 -chunks 参数
+func tableChunks(table config.TableConfig) int {
+	if table.Chunks != nil {
+		return *table.Chunks
+	}
+	return *chunkCount
+}
+
+// anyChunked 判断是否有表需要分片导入
+func anyChunked(tables []config.TableConfig) bool {
+	for _, t := range tables {
+		if tableChunks(t) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlConnsNeeded 估算 MySQL 连接池需要的连接数
+func mysqlConnsNeeded(tables []config.TableConfig) int {
+	perTable := 1
+	for _, t := range tables {
+		n := maxInt(tableWriters(t), 1)
+		if tableChunks(t) > 1 {
+			n *= maxInt(*chunkWorkers, 1)
+		}
+		if n > perTable {
+			perTable = n
+		}
+	}
+	return *workerNum*perTable + 4
+}
+
+// planChunks 决定表是否分片，需要分片时返回分片列表，否则返回 nil
+func planChunks(workerID int, dm *database.DMConnector, table config.TableConfig, dmCols []database.DMColumn) ([]database.ChunkRange, error) {
+	n := tableChunks(table)
+	if n <= 1 {
+		return nil, nil
+	}
+
+	if *chunkMinRows > 0 {
+		count, err := dm.CountRows(table.Name)
+		if err != nil {
+			return nil, err
+		}
+		if count < *chunkMinRows {
+			log.Printf("[Worker %d] 📏 表 %s 共 %d 行，未达到分片阈值 %d，整表导入", workerID, table.Name, count, *chunkMinRows)
+			return nil, nil
+		}
+	}
+
+	chunks, err := dm.PlanChunks(table.Name, dmCols, n, *chunkBy == "quantile")
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) > 0 {
+		log.Printf("[Worker %d] 🧩 表 %s 按 %s 切分为 %d 个分片", workerID, table.Name, chunks[0].Column, len(chunks))
+	}
+	return chunks, nil
+}
+
+// loadChunks 以 -chunk-workers 的并发度读取并写入各分片，所有分片完成后表才算完成
+// 任一分片失败后不再启动新的分片，已在执行的分片会继续到结束
+// 上次运行已完成的分片直接跳过
+func loadChunks(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName, loadTarget string, mysqlCols []database.MySQLColumn, chunks []database.ChunkRange, opts database.InsertOptions, report *migrationReport, ckpt *tableCheckpoint) (database.InsertStats, error) {
+	var pending []database.ChunkRange
+	for _, chunk := range chunks {
+		if !ckpt.chunkIsDone(chunk.Index) {
+			pending = append(pending, chunk)
+		}
+	}
+	report.update(tableName, func(res *tableResult) {
+		res.Chunks = len(chunks)
+		res.ChunksDone = len(chunks) - len(pending)
+	})
+	if len(pending) < len(chunks) {
+		log.Printf("[Worker %d] ♻️  表 %s 已完成 %d/%d 个分片，继续剩余分片", workerID, tableName, len(chunks)-len(pending), len(chunks))
+	}
+
+	var (
+		mu       sync.Mutex
+		total    database.InsertStats
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, maxInt(*chunkWorkers, 1))
+
+	for _, chunk := range pending {
+		mu.Lock()
+		if firstErr == nil && opts.Context != nil && opts.Context.Err() != nil {
+			// 单表超时后不再开始新的分片
+			firstErr = fmt.Errorf("导入被中止: %w", context.Cause(opts.Context))
+		}
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(chunk database.ChunkRange) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			stats, err := loadChunk(dm, mysql, tableName, loadTarget, mysqlCols, chunk, opts, ckpt)
+
+			mu.Lock()
+			total.Add(stats)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("分片 %s: %v", chunk, err)
+			}
+			mu.Unlock()
+
+			if err != nil {
+				log.Printf("[Worker %d] ❌ 表 %s 分片 %s 失败: %v", workerID, tableName, chunk, err)
+				return
+			}
+			ckpt.chunkDone(chunk.Index)
+			var done, all int
+			report.update(tableName, func(res *tableResult) {
+				res.ChunksDone++
+				done, all = res.ChunksDone, res.Chunks
+			})
+			log.Printf("[Worker %d] 🧩 表 %s 分片 %s 完成 (%d 行), 进度 %d/%d", workerID, tableName, chunk, stats.Rows, done, all)
+		}(chunk)
+	}
+	wg.Wait()
+
+	return total, firstErr
+}
+
+// loadChunk 读取并写入单个分片
+// 按整数主键分片时按键升序读取，并把分片内已提交的主键记录到检查点
+func loadChunk(dm *database.DMConnector, mysql *database.MySQLConnector, tableName, loadTarget string, mysqlCols []database.MySQLColumn, chunk database.ChunkRange, opts database.InsertOptions, ckpt *tableCheckpoint) (database.InsertStats, error) {
+	var rows database.RowSource
+	var resumable *database.ResumableRows
+	var err error
+	if ckpt.key != "" {
+		opts.KeyColumn = ckpt.key
+		opts.OnCommit = ckpt.commitChunk(chunk.Index)
+		resumable, err = dm.GetTableDataResumable(tableName, opts.LOB.ReadColumns(mysqlCols), ckpt.key, ckpt.chunkAfter(chunk.Index), &chunk, readRetry())
+		rows = resumable
+	} else {
+		rows, err = dm.GetTableDataRange(tableName, opts.LOB.ReadColumns(mysqlCols), chunk)
+	}
+	if err != nil {
+		return database.InsertStats{}, fmt.Errorf("读数据失败: %v", err)
+	}
+	defer rows.Close()
+	stats, err := mysql.BatchInsertData(loadTarget, mysqlCols, rows, opts)
+	if resumable != nil {
+		stats.Resumes = int64(resumable.Resumes())
+	}
+	return stats, err
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 )
 
@@ -17,7 +18,50 @@ type MySQLConfig struct {
 
 // TablesConfig 表配置
 type TablesConfig struct {
-	Tables []string `json:"tables"`
+	Tables []TableConfig `json:"tables"`
+}
+
+// TableConfig 单表配置
+// 既可以直接写表名字符串，也可以写成对象以覆盖全局参数:
+//
+//	"users"
+//	{"name": "orders", "on_exists": "append"}
+type TableConfig struct {
+	Name      string `json:"name"`
+	OnExists  string `json:"on_exists,omitempty"`  // 目标表已存在时的处理策略，为空则使用 -on-exists
+	Shadow    *bool  `json:"shadow,omitempty"`     // 是否使用影子表导入，为空则使用 -shadow
+	WriteMode string `json:"write_mode,omitempty"` // 写入方式，为空则使用 -write-mode
+	Loader    string `json:"loader,omitempty"`     // 导入方式，为空则使用 -loader
+
+	ErrorBudget *int `json:"error_budget,omitempty"` // 允许写入死信的最大坏行数，为空则使用 -error-budget
+	Chunks      *int `json:"chunks,omitempty"`       // 表内分片数，为空则使用 -chunks
+	Writers     *int `json:"writers,omitempty"`      // 写入协程数，为空则使用 -writers
+
+	AdaptiveBatch *bool `json:"adaptive_batch,omitempty"` // 是否自适应调整批大小，为空则使用 -adaptive-batch
+	LOBStream     *bool `json:"lob_stream,omitempty"`     // 是否分块流式导入大字段，为空则使用 -lob-stream
+
+	Offload []string `json:"offload,omitempty"` // 写入外存目录的大字段列，MySQL 中只保存引用和大小
+
+	Watermark        string `json:"watermark,omitempty"`         // 增量同步的水位列，如 UPDATE_TIME、VERSION 或自增 ID
+	WatermarkOverlap string `json:"watermark_overlap,omitempty"` // 水位回退的重叠区间，为空则使用 -watermark-overlap
+}
+
+// UnmarshalJSON 兼容字符串与对象两种写法
+func (tc *TableConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*tc = TableConfig{Name: name}
+		return nil
+	}
+
+	// 使用别名类型，避免递归调用 UnmarshalJSON
+	type tableConfigAlias TableConfig
+	var alias tableConfigAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*tc = TableConfig(alias)
+	return nil
 }
 
 // Config 迁移配置
@@ -40,5 +84,11 @@ func LoadTablesConfig(filename string) (*TablesConfig, error) {
 		return nil, err
 	}
 
+	for i, t := range tablesConfig.Tables {
+		if t.Name == "" {
+			return nil, fmt.Errorf("第 %d 个表配置缺少表名", i+1)
+		}
+	}
+
 	return &tablesConfig, nil
 }
\ No newline at end of file
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RateSpec 一个方向的限速，0 表示不限制
+type RateSpec struct {
+	RowsPerSec float64 `json:"rows_per_sec,omitempty"` // 每秒行数
+	MBPerSec   float64 `json:"mb_per_sec,omitempty"`   // 每秒 MB 数，按估算的行大小计
+}
+
+// TableRateLimit 单表限速，与全局限速同时生效
+type TableRateLimit struct {
+	Read  *RateSpec `json:"read,omitempty"`
+	Write *RateSpec `json:"write,omitempty"`
+}
+
+// RateLimitConfig 限速文件，运行中修改后自动重新加载:
+//
+//	{
+//	  "read":  {"rows_per_sec": 50000, "mb_per_sec": 20},
+//	  "write": {"mb_per_sec": 40},
+//	  "tables": {"ORDERS": {"read": {"rows_per_sec": 5000}}}
+//	}
+type RateLimitConfig struct {
+	Read   *RateSpec                 `json:"read,omitempty"`   // 全局读取限速，为空则使用 -read-rows-per-sec / -read-mb-per-sec
+	Write  *RateSpec                 `json:"write,omitempty"`  // 全局写入限速，为空则使用 -write-rows-per-sec / -write-mb-per-sec
+	Tables map[string]TableRateLimit `json:"tables,omitempty"` // 表级限速，键为表名
+}
+
+// LoadRateLimitConfig 从JSON文件加载限速配置
+func LoadRateLimitConfig(filename string) (*RateLimitConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg RateLimitConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	check := func(where string, s *RateSpec) error {
+		if s != nil && (s.RowsPerSec < 0 || s.MBPerSec < 0) {
+			return fmt.Errorf("%s 的限速不能为负数", where)
+		}
+		return nil
+	}
+	if err := check("read", cfg.Read); err != nil {
+		return nil, err
+	}
+	if err := check("write", cfg.Write); err != nil {
+		return nil, err
+	}
+	for name, t := range cfg.Tables {
+		if err := check("表 "+name+" read", t.Read); err != nil {
+			return nil, err
+		}
+		if err := check("表 "+name+" write", t.Write); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
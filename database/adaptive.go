@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// minAdaptiveBatch 自适应模式下批大小的下限
+const minAdaptiveBatch = 10
+
+// batchSizer 决定读取端每批攒多少行
+// 固定模式下始终返回初始值；自适应模式下由写入协程上报每批的耗时和错误，
+// 快于目标耗时的满批逐步放大，慢于目标耗时按比例缩小，锁等待、死锁和超时直接减半
+type batchSizer struct {
+	mu        sync.Mutex
+	tableName string
+	adaptive  bool
+	target    time.Duration
+	size      int
+	min       int
+	max       int
+	lowest    int // 运行过程中出现过的最小批大小
+	highest   int // 运行过程中出现过的最大批大小
+}
+
+func newBatchSizer(tableName string, initial, max int, adaptive bool, target time.Duration) *batchSizer {
+	if initial > max {
+		initial = max
+	}
+	min := minAdaptiveBatch
+	if min > initial {
+		min = initial
+	}
+	return &batchSizer{
+		tableName: tableName,
+		adaptive:  adaptive && target > 0,
+		target:    target,
+		size:      initial,
+		min:       min,
+		max:       max,
+		lowest:    initial,
+		highest:   initial,
+	}
+}
+
+// current 返回当前批大小
+func (s *batchSizer) current() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// observe 根据一批的写入结果调整批大小
+func (s *batchSizer) observe(rows int, elapsed time.Duration, err error) {
+	if !s.adaptive {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.size
+	var reason string
+	switch {
+	case err != nil && isSlowBatchError(err):
+		s.size = old / 2
+		reason = err.Error()
+	case err != nil:
+		return
+	case elapsed > s.target*3/2:
+		// 按耗时比例缩小，但每次最多减半，避免单次抖动把批大小压得过低
+		scaled := int(float64(old) * float64(s.target) / float64(elapsed))
+		if scaled < old/2 {
+			scaled = old / 2
+		}
+		s.size = scaled
+		reason = "耗时超过目标"
+	case elapsed < s.target/2 && rows >= old*9/10:
+		// 只有攒满的批次才能说明批大小是瓶颈，受字节预算限制的小批不放大
+		s.size = old + old/2 + 1
+		reason = "耗时低于目标"
+	default:
+		return
+	}
+
+	if s.size < s.min {
+		s.size = s.min
+	}
+	if s.size > s.max {
+		s.size = s.max
+	}
+	if s.size == old {
+		return
+	}
+	if s.size < s.lowest {
+		s.lowest = s.size
+	}
+	if s.size > s.highest {
+		s.highest = s.size
+	}
+	log.Printf("🎚️  表 %s 批大小 %d → %d (%d 行耗时 %v, %s)",
+		s.tableName, old, s.size, rows, elapsed.Round(time.Millisecond), reason)
+}
+
+// record 把出现过的批大小范围写入统计
+func (s *batchSizer) record(stats *InsertStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats.BatchMin = s.lowest
+	stats.BatchMax = s.highest
+}
+
+// isSlowBatchError 判断错误是否说明批次过大: 语句超时、锁等待超时或死锁
+func isSlowBatchError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return isLockError(err)
+}
+
+// isLockError 判断错误是否为锁等待超时或死锁，这两种错误发生时语句已被回滚，可以安全重试
+func isLockError(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassDeadlock, ErrorClassLockWait:
+		return true
+	}
+	return false
+}
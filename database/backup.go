@@ -0,0 +1,146 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// backupInfix 备份表名中表名与运行 ID 之间的分隔符
+const backupInfix = "__bak_"
+
+// mysqlMaxIdentLen MySQL 表名最大长度
+const mysqlMaxIdentLen = 64
+
+// BackupTableName 返回表在指定运行中的备份表名: <表名>__bak_<runID>
+func BackupTableName(tableName, runID string) string {
+	return tableName + backupInfix + runID
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// RenameTable 重命名表
+func (mc *MySQLConnector) RenameTable(from, to string) error {
+	_, err := mc.db.Exec(fmt.Sprintf("RENAME TABLE `%s` TO `%s`", from, to))
+	if err != nil {
+		return fmt.Errorf("rename table error: %v", err)
+	}
+	return nil
+}
+
+// DropTable 删除表
+func (mc *MySQLConnector) DropTable(tableName string) error {
+	_, err := mc.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`", tableName))
+	if err != nil {
+		return fmt.Errorf("drop table error: %v", err)
+	}
+	return nil
+}
+
+// BackupTable 将已存在的目标表重命名为本次运行的备份表，返回备份表名
+func (mc *MySQLConnector) BackupTable(tableName, runID string) (string, error) {
+	backupName := BackupTableName(tableName, runID)
+	if len(backupName) > mysqlMaxIdentLen {
+		return "", fmt.Errorf("备份表名 %s 超过 MySQL 表名长度限制 %d", backupName, mysqlMaxIdentLen)
+	}
+	if err := mc.RenameTable(tableName, backupName); err != nil {
+		return "", err
+	}
+	return backupName, nil
+}
+
+// RestoreBackup 用备份表恢复原表
+// 原表存在时（如导入了一半的数据）通过一条 RENAME 语句原子地换回备份，再删除被换下的表
+func (mc *MySQLConnector) RestoreBackup(tableName, backupName string) error {
+	exists, err := mc.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return mc.RenameTable(backupName, tableName)
+	}
+
+	// 被换下的表复用备份表名的后缀，保证长度不超过备份表名
+	discarded := strings.Replace(backupName, backupInfix, "__rb_", 1)
+	_, err = mc.db.Exec(fmt.Sprintf("RENAME TABLE `%s` TO `%s`, `%s` TO `%s`",
+		tableName, discarded, backupName, tableName))
+	if err != nil {
+		return fmt.Errorf("restore table error: %v", err)
+	}
+	return mc.DropTable(discarded)
+}
+
+// ListBackups 列出某张表的所有备份表，按创建时间从新到旧排序
+// 自定义的运行 ID 不一定按时间有序，不能按表名排序
+func (mc *MySQLConnector) ListBackups(tableName string) ([]string, error) {
+	rows, err := mc.db.Query(`
+		SELECT TABLE_NAME FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME LIKE ?
+		ORDER BY CREATE_TIME IS NULL, CREATE_TIME DESC, TABLE_NAME DESC`,
+		escapeLike(tableName+backupInfix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		backups = append(backups, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
+// ListRunBackups 列出指定运行产生的所有备份表，返回 原表名 -> 备份表名
+func (mc *MySQLConnector) ListRunBackups(runID string) (map[string]string, error) {
+	suffix := backupInfix + runID
+	rows, err := mc.db.Query(
+		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME LIKE ?",
+		"%"+escapeLike(suffix))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backups := make(map[string]string)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		backups[strings.TrimSuffix(name, suffix)] = name
+	}
+	return backups, rows.Err()
+}
+
+// PruneBackups 只保留某张表最近 keep 份备份，删除其余备份，返回被删除的表名
+func (mc *MySQLConnector) PruneBackups(tableName string, keep int) ([]string, error) {
+	backups, err := mc.ListBackups(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+
+	var dropped []string
+	for _, name := range backups[keep:] {
+		if err := mc.DropTable(name); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchWriter 把缓冲的一批行写入 MySQL
+// 开启死信时，失败的批次会被二分拆开重试，定位到具体的坏行
+type batchWriter struct {
+	mc         *MySQLConnector
+	conn       *pinnedConn
+	retry      RetryPolicy
+	tableName  string
+	columns    []MySQLColumn
+	mode       WriteMode
+	deadLetter *DeadLetter
+	stats      *InsertStats
+	sizer      *batchSizer
+
+	baseSQL        string // 如: INSERT INTO `table` (`col1`, `col2`) VALUES
+	rowPlaceholder string // 如: (?, ?)
+	suffixSQL      string // upsert 模式的 ON DUPLICATE KEY UPDATE 子句
+}
+
+func (mc *MySQLConnector) newBatchWriter(conn *pinnedConn, tableName string, columns []MySQLColumn, opts InsertOptions, stats *InsertStats) *batchWriter {
+	mode := opts.WriteMode
+	if mode == "" {
+		mode = WriteModeInsert
+	}
+
+	// 准备 SQL 模板
+	colNames := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, col := range columns {
+		colNames[i] = "`" + col.Name + "`"
+		placeholders[i] = "?"
+	}
+
+	w := &batchWriter{
+		mc:             mc,
+		conn:           conn,
+		tableName:      tableName,
+		columns:        columns,
+		mode:           mode,
+		deadLetter:     opts.DeadLetter,
+		retry:          opts.retryPolicy(),
+		stats:          stats,
+		baseSQL:        fmt.Sprintf("%s `%s` (%s) VALUES ", mode.verb(), tableName, strings.Join(colNames, ", ")),
+		rowPlaceholder: fmt.Sprintf("(%s)", strings.Join(placeholders, ", ")),
+	}
+	if mode == WriteModeUpsert {
+		w.suffixSQL = mc.upsertClause(columns)
+	}
+	return w
+}
+
+// write 写入一批行
+func (w *batchWriter) write(rows [][]interface{}) error {
+	start := time.Now()
+	err := w.exec(rows)
+	w.sizer.observe(len(rows), time.Since(start), err)
+	if err == nil {
+		return nil
+	}
+
+	// 自适应模式下锁等待或死锁说明批次过大，语句已被回滚，按缩小后的批大小拆开重写
+	if w.sizer.adaptive && isLockError(err) && len(rows) > 1 {
+		size := w.sizer.current()
+		if size >= len(rows) {
+			size = len(rows) / 2
+		}
+		log.Printf("🔁 表 %s 的一批数据 (%d 行) 遇到锁冲突，拆分为每批 %d 行重试: %v", w.tableName, len(rows), size, err)
+		for i := 0; i < len(rows); i += size {
+			if err := w.write(rows[i:min(i+size, len(rows))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if w.deadLetter == nil || !isRowLevelError(err) {
+		return err
+	}
+
+	log.Printf("🔍 表 %s 的一批数据 (%d 行) 写入失败，开始定位坏行: %v", w.tableName, len(rows), err)
+	return w.isolate(rows, err)
+}
+
+// isolate 二分拆分失败的批次: 成功的半批正常写入，单独失败的行写入死信文件
+func (w *batchWriter) isolate(rows [][]interface{}, cause error) error {
+	if len(rows) == 1 {
+		w.stats.Failed++
+		return w.deadLetter.Write(w.tableName, w.columns, rows[0], cause)
+	}
+
+	mid := len(rows) / 2
+	for _, half := range [][][]interface{}{rows[:mid], rows[mid:]} {
+		err := w.exec(half)
+		if err == nil {
+			continue
+		}
+		if !isRowLevelError(err) {
+			return err
+		}
+		if err := w.isolate(half, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec 写入一批数据，语句超过 max_allowed_packet 时拆成两半分别写入
+func (w *batchWriter) exec(rows [][]interface{}) error {
+	err := splitOnPacketTooLarge(rows, w.execOnce)
+	// 拆到单行仍然超限时给出明确的提示
+	var single *oversizedRow
+	if errors.As(err, &single) {
+		return w.mc.oversizedRowError(w.tableName, estimateRowBytes(single.row))
+	}
+	return err
+}
+
+// oversizedRow 单行语句仍超过 max_allowed_packet
+type oversizedRow struct {
+	row []interface{}
+	err error
+}
+
+func (e *oversizedRow) Error() string { return e.err.Error() }
+
+// splitOnPacketTooLarge 用 exec 写入 rows，语句超限时拆成两半递归写入
+// 每批的字节数是估算的，二进制或转义较多的数据插值后可能接近原长的两倍，整批超限时拆小重写而不是让整张表失败；
+// 超限的语句没有执行，拆分后不会重复写入
+func splitOnPacketTooLarge(rows [][]interface{}, exec func([][]interface{}) error) error {
+	err := exec(rows)
+	if err == nil || !isPacketTooLarge(err) {
+		return err
+	}
+	if len(rows) == 1 {
+		return &oversizedRow{row: rows[0], err: err}
+	}
+	mid := len(rows) / 2
+	if err := splitOnPacketTooLarge(rows[:mid], exec); err != nil {
+		return err
+	}
+	return splitOnPacketTooLarge(rows[mid:], exec)
+}
+
+// execOnce 以一条多行语句写入并按写入方式累计统计
+func (w *batchWriter) execOnce(rows [][]interface{}) error {
+	placeholders := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*len(w.columns))
+	for i, row := range rows {
+		placeholders[i] = w.rowPlaceholder
+		args = append(args, row...)
+	}
+	stmt := w.baseSQL + strings.Join(placeholders, ",") + w.suffixSQL
+
+	// 每批一个事务，重试时整批回滚后重写，不会重复写入
+	var affected int64
+	retries, err := w.conn.withRetry(w.retry, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		result, err := tx.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	w.stats.Retries += int64(retries)
+	if err != nil {
+		return err
+	}
+	w.mode.account(w.stats, int64(len(rows)), affected)
+	return nil
+}
+
+// pendingBatch 读取端交给写入端的一批数据
+type pendingBatch struct {
+	seq   int64 // 批次序号，从 0 开始连续编号
+	rows  [][]interface{}
+	mark  batchMark // 按键有序读取时该批的最大键值
+	bytes int64     // 该批的估算字节数，开启内存预算时写完后释放
+
+	offloaded []OffloadEntry // 该批外存列的清单记录，提交后写入清单
+}
+
+// writerPool 写入端: 多个写入协程从有界通道中领取批次，每个协程固定使用一条 MySQL 连接
+type writerPool struct {
+	batches chan pendingBatch
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	writers int
+
+	templateLen int            // 不含数据的语句模板长度，读取端据此扣减每批的字节预算
+	sizer       *batchSizer    // 读取端与所有写入协程共享的批大小
+	tracker     *commitTracker // 不为空时按批次提交顺序推进检查点
+	nextSeq     int64          // 下一批的序号，只由读取端协程修改
+	memory      *MemoryBudget  // 在途批次的内存预算，可以为空
+
+	mu        sync.Mutex
+	stats     InsertStats
+	err       error
+	readWait  time.Duration // 所有写入协程等待读取端的时间之和
+	writeWait time.Duration // 读取端等待写入协程的时间，只由读取端协程修改
+}
+
+// startWriters 启动 opts.Writers 个写入协程
+// 通道容量与写入协程数相同，读取端最多领先写入端一轮，内存占用有上限
+func (mc *MySQLConnector) startWriters(tableName string, columns []MySQLColumn, opts InsertOptions, sizer *batchSizer) *writerPool {
+	ctx, cancel := context.WithCancel(opts.ctx())
+	p := &writerPool{
+		batches: make(chan pendingBatch, opts.Writers),
+		ctx:     ctx,
+		cancel:  cancel,
+		writers: opts.Writers,
+		sizer:   sizer,
+		memory:  opts.Memory,
+	}
+	tpl := mc.newBatchWriter(nil, tableName, columns, opts, nil)
+	p.templateLen = len(tpl.baseSQL) + len(tpl.suffixSQL)
+	for i := 0; i < opts.Writers; i++ {
+		p.wg.Add(1)
+		go p.run(mc, tableName, columns, opts)
+	}
+	return p
+}
+
+// run 单个写入协程的主循环
+func (p *writerPool) run(mc *MySQLConnector, tableName string, columns []MySQLColumn, opts InsertOptions) {
+	defer p.wg.Done()
+
+	conn := &pinnedConn{db: mc.writeDB}
+	defer conn.Close()
+
+	var stats InsertStats
+	var idle time.Duration
+	writer := mc.newBatchWriter(conn, tableName, columns, opts, &stats)
+	writer.sizer = p.sizer
+	for {
+		start := time.Now()
+		var batch pendingBatch
+		var ok bool
+		select {
+		case batch, ok = <-p.batches:
+		case <-p.ctx.Done():
+		}
+		idle += time.Since(start)
+		if !ok {
+			break
+		}
+
+		waited, err := opts.WriteLimit.Wait(p.ctx, int64(len(batch.rows)), batch.bytes)
+		stats.RateWait += waited
+		if err == nil {
+			waited, err = opts.Guard.Wait(p.ctx)
+			stats.GuardWait += waited
+		}
+		if err != nil {
+			p.memory.Release(batch.bytes)
+			break
+		}
+
+		log.Printf("📤 正在插入表 %s 的一批数据 (%d 行)", tableName, len(batch.rows))
+		err = writer.write(batch.rows)
+		p.memory.Release(batch.bytes)
+		if err != nil {
+			p.fail(err)
+			break
+		}
+		// 先记清单再推进检查点，续传时不会跳过清单中缺失的行
+		if err := opts.Offload.Record(batch.offloaded); err != nil {
+			p.fail(err)
+			break
+		}
+		if p.tracker != nil {
+			p.tracker.done(batch.seq, batch.mark)
+		}
+		log.Printf("📥 表 %s 的一批数据插入完成 (%d 行)", tableName, len(batch.rows))
+	}
+
+	p.mu.Lock()
+	p.stats.Add(stats)
+	p.readWait += idle
+	p.mu.Unlock()
+}
+
+// fail 记录第一个写入错误并通知读取端和其他写入协程停止
+func (p *writerPool) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// submit 把一批数据交给写入端，写入端已失败时返回 false
+// mark 为该批的最大键值和行数，未开启检查点时忽略；bytes 为该批占用的内存预算，交给写入端释放
+// offloaded 为该批外存列的清单记录，写入端提交后记入清单
+func (p *writerPool) submit(rows [][]interface{}, mark batchMark, bytes int64, offloaded []OffloadEntry) bool {
+	start := time.Now()
+	defer func() { p.writeWait += time.Since(start) }()
+	batch := pendingBatch{seq: p.nextSeq, rows: rows, mark: mark, bytes: bytes, offloaded: offloaded}
+	p.nextSeq++
+	select {
+	case p.batches <- batch:
+		return true
+	case <-p.ctx.Done():
+		p.memory.Release(bytes)
+		return false
+	}
+}
+
+// close 通知写入端数据已读完，等待所有批次写完后返回写入统计
+func (p *writerPool) close() (InsertStats, error) {
+	close(p.batches)
+	p.wg.Wait()
+	p.cancel()
+	// 写入端失败后通道中剩下的批次不会再写，归还它们占用的预算
+	for batch := range p.batches {
+		p.memory.Release(batch.bytes)
+	}
+
+	stats := p.stats
+	stats.ReadWait = p.readWait / time.Duration(p.writers)
+	stats.WriteWait = p.writeWait
+	return stats, p.err
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestSplitOnPacketTooLarge(t *testing.T) {
+	rows := make([][]interface{}, 7)
+	for i := range rows {
+		rows[i] = []interface{}{i}
+	}
+
+	// 超过 2 行的语句超限，拆到 2 行以内后写入
+	var written [][]int
+	err := splitOnPacketTooLarge(rows, func(batch [][]interface{}) error {
+		if len(batch) > 2 {
+			return mysql.ErrPktTooLarge
+		}
+		var ids []int
+		for _, row := range batch {
+			ids = append(ids, row[0].(int))
+		}
+		written = append(written, ids)
+		return nil
+	})
+	want := [][]int{{0}, {1, 2}, {3, 4}, {5, 6}}
+	if err != nil || !reflect.DeepEqual(written, want) {
+		t.Fatalf("splitOnPacketTooLarge = %v, written %v; want nil, %v", err, written, want)
+	}
+
+	// 拆到单行仍然超限时报告该行
+	err = splitOnPacketTooLarge(rows, func(batch [][]interface{}) error {
+		for _, row := range batch {
+			if row[0] == 5 {
+				return &mysql.MySQLError{Number: 1153, Message: "Got a packet bigger than 'max_allowed_packet' bytes"}
+			}
+		}
+		return nil
+	})
+	var single *oversizedRow
+	if !errors.As(err, &single) || single.row[0] != 5 {
+		t.Fatalf("splitOnPacketTooLarge = %v, want oversized row 5", err)
+	}
+
+	// 其他错误原样返回，不拆分
+	fail := errors.New("lost connection")
+	calls := 0
+	err = splitOnPacketTooLarge(rows, func(batch [][]interface{}) error {
+		calls++
+		return fail
+	})
+	if err != fail || calls != 1 {
+		t.Fatalf("splitOnPacketTooLarge = %v after %d calls, want %v after 1", err, calls, fail)
+	}
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ChangeLogTable 触发器写入的变更日志表
+const ChangeLogTable = "DM2MYSQL_CHANGELOG"
+
+// MaxChangeKeyColumns 变更日志最多记录的主键列数
+const MaxChangeKeyColumns = 4
+
+// changeKeyLen 变更日志中每个主键值的最大长度
+const changeKeyLen = 512
+
+// ChangeLogEntry 变更日志中的一条记录
+type ChangeLogEntry struct {
+	ID    int64
+	Table string
+	Op    string   // I / U / D
+	Keys  []string // 主键值，顺序与主键列一致
+}
+
+// CDCTriggerName 返回表的变更捕获触发器名
+func CDCTriggerName(tableName string) string {
+	return "DM2MYSQL_CDC_" + strings.ToUpper(tableName)
+}
+
+// PrimaryKeyColumns 返回主键列名，顺序与列定义一致
+func PrimaryKeyColumns(cols []DMColumn) []string {
+	var keys []string
+	for _, col := range cols {
+		if col.IsPrimaryKey {
+			keys = append(keys, col.Name)
+		}
+	}
+	return keys
+}
+
+// EnsureChangeLog 创建变更日志表，已存在时不做任何事
+func (dmc *DMConnector) EnsureChangeLog() error {
+	var count int
+	err := dmc.db.QueryRow("SELECT COUNT(*) FROM USER_TABLES WHERE TABLE_NAME = ?", ChangeLogTable).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	keyCols := make([]string, MaxChangeKeyColumns)
+	for i := range keyCols {
+		keyCols[i] = fmt.Sprintf("PK%d VARCHAR(%d)", i+1, changeKeyLen)
+	}
+	ddl := fmt.Sprintf(`CREATE TABLE %s (
+		ID BIGINT IDENTITY(1, 1) PRIMARY KEY,
+		TABLE_NAME VARCHAR(128) NOT NULL,
+		OP CHAR(1) NOT NULL,
+		%s,
+		CHANGE_TIME TIMESTAMP DEFAULT SYSTIMESTAMP
+	)`, ChangeLogTable, strings.Join(keyCols, ",\n\t\t"))
+	if _, err := dmc.db.Exec(ddl); err != nil {
+		return fmt.Errorf("create changelog error: %v", err)
+	}
+	return nil
+}
+
+// InstallCDCTrigger 为表安装行级触发器，把 INSERT/UPDATE/DELETE 的主键写入变更日志
+// 修改主键的 UPDATE 会同时记录旧主键，消费端据此删除旧行
+func (dmc *DMConnector) InstallCDCTrigger(tableName string, cols []DMColumn) error {
+	realTableName := dmc.getRealTableName(tableName)
+	keys := PrimaryKeyColumns(cols)
+	if len(keys) == 0 {
+		return fmt.Errorf("表 %s 没有主键，无法捕获变更", tableName)
+	}
+	if len(keys) > MaxChangeKeyColumns {
+		return fmt.Errorf("表 %s 的主键有 %d 列，超过变更日志支持的 %d 列", tableName, len(keys), MaxChangeKeyColumns)
+	}
+
+	logInsert := func(op, ref string) string {
+		cols := []string{"TABLE_NAME", "OP"}
+		vals := []string{quoteDMString(realTableName), quoteDMString(op)}
+		for i, k := range keys {
+			cols = append(cols, fmt.Sprintf("PK%d", i+1))
+			vals = append(vals, ref+"."+quoteDMIdent(k))
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", ChangeLogTable, strings.Join(cols, ", "), strings.Join(vals, ", "))
+	}
+	var keyChanged []string
+	for _, k := range keys {
+		keyChanged = append(keyChanged, fmt.Sprintf(":OLD.%s <> :NEW.%s", quoteDMIdent(k), quoteDMIdent(k)))
+	}
+
+	ddl := fmt.Sprintf(`CREATE OR REPLACE TRIGGER %s
+AFTER INSERT OR UPDATE OR DELETE ON %s
+FOR EACH ROW
+BEGIN
+	IF INSERTING THEN
+		%s
+	ELSIF UPDATING THEN
+		IF %s THEN
+			%s
+		END IF;
+		%s
+	ELSE
+		%s
+	END IF;
+END;`,
+		quoteDMIdent(CDCTriggerName(realTableName)), realTableName,
+		logInsert("I", ":NEW"),
+		strings.Join(keyChanged, " OR "), logInsert("D", ":OLD"),
+		logInsert("U", ":NEW"),
+		logInsert("D", ":OLD"))
+	if _, err := dmc.db.Exec(ddl); err != nil {
+		return fmt.Errorf("create trigger error: %v", err)
+	}
+	return nil
+}
+
+// UninstallCDCTrigger 删除表的变更捕获触发器
+func (dmc *DMConnector) UninstallCDCTrigger(tableName string) error {
+	realTableName := dmc.getRealTableName(tableName)
+	var count int
+	err := dmc.db.QueryRow("SELECT COUNT(*) FROM USER_TRIGGERS WHERE TRIGGER_NAME = ?", CDCTriggerName(realTableName)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	if _, err := dmc.db.Exec("DROP TRIGGER " + quoteDMIdent(CDCTriggerName(realTableName))); err != nil {
+		return fmt.Errorf("drop trigger error: %v", err)
+	}
+	return nil
+}
+
+// DropChangeLog 删除变更日志表
+func (dmc *DMConnector) DropChangeLog() error {
+	var count int
+	err := dmc.db.QueryRow("SELECT COUNT(*) FROM USER_TABLES WHERE TABLE_NAME = ?", ChangeLogTable).Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+	_, err = dmc.db.Exec("DROP TABLE " + ChangeLogTable)
+	return err
+}
+
+// ReadChangeLog 按 ID 顺序读取最多 limit 条变更
+// 已消费的记录会被删除，因此每次都从头读取；晚提交的事务即使 ID 较小也不会被跳过
+func (dmc *DMConnector) ReadChangeLog(limit int) ([]ChangeLogEntry, error) {
+	keyCols := make([]string, MaxChangeKeyColumns)
+	for i := range keyCols {
+		keyCols[i] = fmt.Sprintf("PK%d", i+1)
+	}
+	query := fmt.Sprintf("SELECT ID, TABLE_NAME, OP, %s FROM %s ORDER BY ID LIMIT %d",
+		strings.Join(keyCols, ", "), ChangeLogTable, limit)
+	rows, err := dmc.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ChangeLogEntry
+	for rows.Next() {
+		var e ChangeLogEntry
+		keys := make([]sql.NullString, MaxChangeKeyColumns)
+		dest := []interface{}{&e.ID, &e.Table, &e.Op}
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if !k.Valid {
+				break
+			}
+			e.Keys = append(e.Keys, k.String)
+		}
+		e.Op = strings.TrimSpace(e.Op)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// PurgeChangeLog 删除已消费的变更
+func (dmc *DMConnector) PurgeChangeLog(ids []int64) error {
+	const step = 1000
+	for start := 0; start < len(ids); start += step {
+		end := start + step
+		if end > len(ids) {
+			end = len(ids)
+		}
+		holders := make([]string, end-start)
+		args := make([]interface{}, end-start)
+		for i, id := range ids[start:end] {
+			holders[i] = "?"
+			args[i] = id
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE ID IN (%s)", ChangeLogTable, strings.Join(holders, ", "))
+		if _, err := dmc.db.Exec(query, args...); err != nil {
+			return fmt.Errorf("purge changelog error: %v", err)
+		}
+	}
+	return nil
+}
+
+// keyMatch 生成按多组主键匹配的 WHERE 条件: (k1 = ? AND k2 = ?) OR (...)
+func keyMatch(keyExprs []string, keys [][]string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, key := range keys {
+		parts := make([]string, len(keyExprs))
+		for i, expr := range keyExprs {
+			parts[i] = expr + " = ?"
+			args = append(args, key[i])
+		}
+		conds = append(conds, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// ExistingKeys 返回给定主键中仍存在于源表的部分，主键值按变更日志中的字符串形式返回
+func (dmc *DMConnector) ExistingKeys(tableName string, keyCols []string, keys [][]string) ([][]string, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	exprs := make([]string, len(keyCols))
+	casts := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		exprs[i] = quoteDMIdent(k)
+		// 与触发器写入变更日志时相同的隐式转换，保证字符串形式一致
+		casts[i] = fmt.Sprintf("CAST(%s AS VARCHAR(%d))", quoteDMIdent(k), changeKeyLen)
+	}
+	where, args := keyMatch(exprs, keys)
+	rows, err := dmc.db.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(casts, ", "), realTableName, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found [][]string
+	for rows.Next() {
+		key := make([]string, len(keyCols))
+		dest := make([]interface{}, len(keyCols))
+		for i := range key {
+			dest[i] = &key[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		found = append(found, key)
+	}
+	return found, rows.Err()
+}
+
+// GetRowsByKeys 按主键读取源表当前的行
+func (dmc *DMConnector) GetRowsByKeys(tableName string, cols []string, keyCols []string, keys [][]string) (*sql.Rows, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	exprs := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		exprs[i] = quoteDMIdent(k)
+	}
+	where, args := keyMatch(exprs, keys)
+	return dmc.db.Query(fmt.Sprintf("SELECT %s FROM %s WHERE %s", selectList(cols), realTableName, where), args...)
+}
+
+// DeleteByKeys 按主键删除目标表的行，返回删除的行数
+func (mc *MySQLConnector) DeleteByKeys(tableName string, keyCols []string, keys [][]string) (int64, error) {
+	exprs := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		exprs[i] = "`" + k + "`"
+	}
+	where, args := keyMatch(exprs, keys)
+	result, err := mc.db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE %s", tableName, where), args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete rows error: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// quoteDMString 生成达梦字符串字面量
+func quoteDMString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
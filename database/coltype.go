@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columnType 解析后的 MySQL 列类型，用于比较已存在的目标表能否容纳源列
+type columnType struct {
+	base      string // 小写的类型名，如 int、decimal、varchar
+	length    int64  // 字符类型的最大字符数
+	precision int    // decimal 的总位数
+	scale     int    // decimal 的小数位数，datetime 的秒精度
+	unsigned  bool
+}
+
+// integerDigits 各整数类型能完整容纳的十进制位数
+var integerDigits = map[string]int{
+	"tinyint": 2, "smallint": 4, "mediumint": 6, "int": 9, "bigint": 18,
+}
+
+// textLengths 各文本类型的最大字符数 (按单字节字符计)
+var textLengths = map[string]int64{
+	"tinytext": 255, "text": 65535, "mediumtext": 16777215, "longtext": 4294967295,
+}
+
+// blobRanks 二进制大对象类型由小到大的顺序
+var blobRanks = map[string]int{
+	"tinyblob": 1, "blob": 2, "mediumblob": 3, "longblob": 4,
+}
+
+// parseColumnType 解析 convertDMTypeToMySQL 生成的类型，如 DECIMAL(20,4)、VARCHAR(200)、DATETIME(6)
+func parseColumnType(s string) columnType {
+	s = strings.ToLower(strings.TrimSpace(s))
+	t := columnType{base: s}
+	if i := strings.Index(s, "("); i >= 0 && strings.HasSuffix(s, ")") {
+		t.base = s[:i]
+		args := strings.Split(s[i+1:len(s)-1], ",")
+		first, _ := strconv.Atoi(strings.TrimSpace(args[0]))
+		switch t.base {
+		case "char", "varchar":
+			t.length = int64(first)
+		case "decimal":
+			t.precision = first
+			if len(args) > 1 {
+				t.scale, _ = strconv.Atoi(strings.TrimSpace(args[1]))
+			}
+		case "datetime", "timestamp":
+			t.scale = first
+		}
+	}
+	if n, ok := textLengths[t.base]; ok {
+		t.length = n
+	}
+	return t
+}
+
+// expectedColumnType 源列在 MySQL 中需要的类型
+// 字符串列因过长映射为 TEXT 类时只要求容纳源列的长度，不要求整个 TEXT 的容量
+func expectedColumnType(col MySQLColumn, version int) (columnType, string) {
+	text := convertDMTypeToMySQL(col, version)
+	t := parseColumnType(text)
+	if _, ok := textLengths[t.base]; ok && !IsLOBType(col.DataType) && col.DataLength > 0 {
+		t.length = col.DataLength
+	}
+	return t, text
+}
+
+// holds 判断目标列类型 have 能否无损容纳期望的类型 want
+func (want columnType) holds(have columnType) bool {
+	if digits, ok := integerDigits[want.base]; ok {
+		if have.unsigned {
+			// 无符号列放不下负数
+			return false
+		}
+		if have.base == "decimal" {
+			return have.precision-have.scale > digits
+		}
+		haveDigits, ok := integerDigits[have.base]
+		return ok && haveDigits >= digits
+	}
+
+	switch want.base {
+	case "decimal":
+		return have.base == "decimal" && !have.unsigned &&
+			have.scale >= want.scale && have.precision-have.scale >= want.precision-want.scale
+	case "double":
+		return have.base == "double"
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		switch have.base {
+		case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+			return have.length >= want.length
+		}
+		return false
+	case "datetime":
+		return have.base == "datetime" && have.scale >= want.scale
+	}
+	if rank, ok := blobRanks[want.base]; ok {
+		return blobRanks[have.base] >= rank
+	}
+	return have.base == want.base
+}
+
+// targetColumnType 由 information_schema.COLUMNS 中的信息构造目标列类型
+func targetColumnType(dataType, columnTypeText string, charLength int64, precision, scale, datetimePrecision int) columnType {
+	t := columnType{base: strings.ToLower(dataType)}
+	switch {
+	case t.base == "decimal":
+		t.precision, t.scale = precision, scale
+	case t.base == "datetime" || t.base == "timestamp":
+		t.scale = datetimePrecision
+	default:
+		t.length = charLength
+	}
+	t.unsigned = strings.Contains(strings.ToLower(columnTypeText), "unsigned")
+	return t
+}
+
+// describeMismatch 描述一列类型不兼容的原因
+func describeMismatch(col MySQLColumn, want, have string) string {
+	return fmt.Sprintf("%s (目标为 %s, 源类型 %s 需要 %s)", col.Name, have, col.DataType, want)
+}
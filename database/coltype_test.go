@@ -0,0 +1,62 @@
+package database
+
+import "testing"
+
+func TestColumnTypeHolds(t *testing.T) {
+	tests := []struct {
+		name string
+		col  MySQLColumn
+		have columnType
+		ok   bool
+	}{
+		{"same decimal", MySQLColumn{DataType: "DECIMAL", DataPrecision: 20, DataScale: 4}, columnType{base: "decimal", precision: 20, scale: 4}, true},
+		{"wider decimal", MySQLColumn{DataType: "NUMBER", DataPrecision: 20, DataScale: 4}, columnType{base: "decimal", precision: 30, scale: 6}, true},
+		{"decimal into int", MySQLColumn{DataType: "DECIMAL", DataPrecision: 20, DataScale: 4}, columnType{base: "int"}, false},
+		{"decimal fewer scale", MySQLColumn{DataType: "DECIMAL", DataPrecision: 20, DataScale: 4}, columnType{base: "decimal", precision: 20, scale: 2}, false},
+		{"decimal fewer integer digits", MySQLColumn{DataType: "DECIMAL", DataPrecision: 20, DataScale: 4}, columnType{base: "decimal", precision: 18, scale: 4}, false},
+		{"int into bigint", MySQLColumn{DataType: "INT"}, columnType{base: "bigint"}, true},
+		{"bigint into int", MySQLColumn{DataType: "BIGINT"}, columnType{base: "int"}, false},
+		{"int into unsigned int", MySQLColumn{DataType: "INT"}, columnType{base: "int", unsigned: true}, false},
+		{"int into decimal", MySQLColumn{DataType: "INT"}, columnType{base: "decimal", precision: 10, scale: 0}, true},
+		{"bigint into short decimal", MySQLColumn{DataType: "BIGINT"}, columnType{base: "decimal", precision: 18, scale: 0}, false},
+		{"varchar shorter", MySQLColumn{DataType: "VARCHAR2", DataLength: 200}, columnType{base: "varchar", length: 10}, false},
+		{"varchar longer", MySQLColumn{DataType: "VARCHAR2", DataLength: 200}, columnType{base: "varchar", length: 255}, true},
+		{"varchar into text", MySQLColumn{DataType: "VARCHAR2", DataLength: 200}, columnType{base: "text", length: 65535}, true},
+		{"long varchar into varchar", MySQLColumn{DataType: "VARCHAR", DataLength: 8000}, columnType{base: "varchar", length: 8000}, true},
+		{"clob into text", MySQLColumn{DataType: "CLOB"}, columnType{base: "text", length: 65535}, false},
+		{"clob into longtext", MySQLColumn{DataType: "CLOB"}, columnType{base: "longtext", length: 4294967295}, true},
+		{"blob into mediumblob", MySQLColumn{DataType: "BLOB"}, columnType{base: "mediumblob"}, false},
+		{"timestamp fsp", MySQLColumn{DataType: "TIMESTAMP"}, columnType{base: "datetime", scale: 0}, false},
+		{"timestamp fsp 6", MySQLColumn{DataType: "TIMESTAMP"}, columnType{base: "datetime", scale: 6}, true},
+		{"date into timestamp", MySQLColumn{DataType: "DATE"}, columnType{base: "timestamp"}, false},
+		{"double into float", MySQLColumn{DataType: "DOUBLE"}, columnType{base: "float"}, false},
+		{"offload reference", MySQLColumn{DataType: "BLOB", Offload: true}, columnType{base: "varchar", length: 255}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, text := expectedColumnType(tt.col, 8)
+			if got := want.holds(tt.have); got != tt.ok {
+				t.Errorf("%s holds %+v = %v, want %v", text, tt.have, got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want columnType
+	}{
+		{"DECIMAL(20,4)", columnType{base: "decimal", precision: 20, scale: 4}},
+		{"VARCHAR(200)", columnType{base: "varchar", length: 200}},
+		{"DATETIME(6)", columnType{base: "datetime", scale: 6}},
+		{"TINYINT(1)", columnType{base: "tinyint"}},
+		{"LONGTEXT", columnType{base: "longtext", length: 4294967295}},
+		{"BIGINT", columnType{base: "bigint"}},
+	}
+	for _, tt := range tests {
+		if got := parseColumnType(tt.in); got != tt.want {
+			t.Errorf("parseColumnType(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columnConverter 把驱动扫描出的值转换为写入 MySQL 的值，nil 已在调用前处理
+type columnConverter func(v interface{}) (interface{}, error)
+
+// ColumnNames 返回需要从源表读取的列名，读取源表时按此顺序列出列，保证与写入的列一一对应
+// 外存的大小列由转换时生成，不从源表读取
+func ColumnNames(cols []MySQLColumn) []string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if col.SizeOf == "" {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// selectList 生成 SELECT 的列清单，未指定列时退回 *
+func selectList(cols []string) string {
+	if len(cols) == 0 {
+		return "*"
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quoteDMIdent(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// rowConverter 把扫描出的一行源数据转换为写入 MySQL 的一行
+// 外存列的值写入外部存储后替换为引用，对应的大小列填入原始字节数
+type rowConverter struct {
+	columns []MySQLColumn
+	convs   []columnConverter // 与 columns 一一对应，大小列为 nil
+	offload *Offloader
+	sizeIdx map[string]int // 外存列名 -> 大小列下标
+	keyIdx  []int          // 主键列下标，外存清单中用来标识行
+
+	offloaded []OffloadEntry // 已外存但尚未提交的清单记录，由 takeOffloaded 取走
+}
+
+// newRowConverter 按每列的达梦类型和映射后的 MySQL 类型选择转换器
+func newRowConverter(columns []MySQLColumn, version int, offload *Offloader) *rowConverter {
+	rc := &rowConverter{
+		columns: columns,
+		convs:   make([]columnConverter, len(columns)),
+		offload: offload,
+		sizeIdx: make(map[string]int),
+	}
+	for i, col := range columns {
+		switch {
+		case col.SizeOf != "":
+			rc.sizeIdx[col.SizeOf] = i
+		case col.Offload:
+			rc.convs[i] = convertBinary
+		default:
+			rc.convs[i] = converterFor(col, version)
+		}
+		if col.IsPrimaryKey {
+			rc.keyIdx = append(rc.keyIdx, i)
+		}
+	}
+	return rc
+}
+
+// width 返回每行从源表扫描的值个数
+func (rc *rowConverter) width() int {
+	return len(rc.columns) - len(rc.sizeIdx)
+}
+
+// convert 转换一行数据，返回新的切片，扫描容器可以继续复用
+func (rc *rowConverter) convert(values []interface{}) ([]interface{}, error) {
+	row := make([]interface{}, len(rc.columns))
+	j := 0
+	for i, col := range rc.columns {
+		if col.SizeOf != "" {
+			continue
+		}
+		v := values[j]
+		j++
+		if v == nil {
+			continue
+		}
+		cv, err := rc.convs[i](v)
+		if err != nil {
+			return nil, fmt.Errorf("列 %s (%s): %v", col.Name, col.DataType, err)
+		}
+		row[i] = cv
+	}
+
+	// 主键转换完成后再外存，清单中才能记录完整的行标识
+	for i, col := range rc.columns {
+		if !col.Offload || row[i] == nil {
+			continue
+		}
+		if rc.offload == nil {
+			return nil, fmt.Errorf("列 %s 配置了外存但未指定外存目录", col.Name)
+		}
+		data := row[i].([]byte)
+		key := make(map[string]interface{}, len(rc.keyIdx))
+		for _, k := range rc.keyIdx {
+			key[rc.columns[k].Name] = row[k]
+		}
+		entry, err := rc.offload.Put(col.Name, key, data)
+		if err != nil {
+			return nil, fmt.Errorf("列 %s: %v", col.Name, err)
+		}
+		rc.offloaded = append(rc.offloaded, entry)
+		row[i] = entry.Path
+		if s, ok := rc.sizeIdx[col.Name]; ok {
+			row[s] = int64(len(data))
+		}
+	}
+	return row, nil
+}
+
+// takeOffloaded 取走上次取走之后转换的行的外存清单记录，调用方在这些行提交后交给 Offloader.Record
+func (rc *rowConverter) takeOffloaded() []OffloadEntry {
+	entries := rc.offloaded
+	rc.offloaded = nil
+	return entries
+}
+
+// converterFor 选择单列的转换器
+func converterFor(col MySQLColumn, version int) columnConverter {
+	target := convertDMTypeToMySQL(col, version)
+	switch {
+	case target == "TINYINT(1)":
+		return convertBool
+	case target == "TINYINT", target == "SMALLINT", target == "INT", target == "BIGINT":
+		return convertInteger
+	case strings.HasPrefix(target, "DECIMAL"):
+		return convertDecimal
+	case target == "DOUBLE":
+		return convertFloat
+	case target == "DATETIME(6)":
+		return convertTime(time.Microsecond)
+	case target == "DATETIME":
+		return convertTime(time.Second)
+	case strings.Contains(target, "BLOB"):
+		return convertBinary
+	}
+	return convertText
+}
+
+// convertBool BIT/BOOL 写入 TINYINT(1)，统一为 0/1
+func convertBool(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case []byte:
+		return parseBool(string(x))
+	case string:
+		return parseBool(x)
+	}
+	if n, ok := keyToInt64(v); ok {
+		if n != 0 {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, fmt.Errorf("无法转换为布尔值: %T", v)
+}
+
+func parseBool(s string) (interface{}, error) {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("无法转换为布尔值: %q", s)
+	}
+	return convertBool(b)
+}
+
+// convertInteger 整数列保持整数，字符串形式的整数原样写入
+func convertInteger(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case []byte:
+		return strings.TrimSpace(string(x)), nil
+	case string:
+		return strings.TrimSpace(x), nil
+	case float64:
+		if x != math.Trunc(x) || math.Abs(x) > 1<<53 {
+			return nil, fmt.Errorf("浮点值 %v 无法精确转换为整数", x)
+		}
+		return int64(x), nil
+	case float32:
+		return convertInteger(float64(x))
+	case bool:
+		return convertBool(x)
+	}
+	if n, ok := keyToInt64(v); ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("无法转换为整数: %T", v)
+}
+
+// convertDecimal 精确小数一律以十进制字符串写入，避免经过 float64 丢失精度
+func convertDecimal(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case []byte:
+		return strings.TrimSpace(string(x)), nil
+	case string:
+		return strings.TrimSpace(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32), nil
+	case fmt.Stringer:
+		return x.String(), nil
+	}
+	if n, ok := keyToInt64(v); ok {
+		return strconv.FormatInt(n, 10), nil
+	}
+	return nil, fmt.Errorf("无法转换为小数: %T", v)
+}
+
+// convertFloat 浮点列保持浮点数
+func convertFloat(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case []byte:
+		return strings.TrimSpace(string(x)), nil
+	case string:
+		return strings.TrimSpace(x), nil
+	}
+	if n, ok := keyToInt64(v); ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("无法转换为浮点数: %T", v)
+}
+
+// convertTime 按目标列精度截断时间
+// MySQL 写入时会把多余的小数秒四舍五入，可能进位到下一秒，因此在写入前截断
+func convertTime(precision time.Duration) columnConverter {
+	return func(v interface{}) (interface{}, error) {
+		switch x := v.(type) {
+		case time.Time:
+			return x.Truncate(precision), nil
+		case []byte:
+			return string(x), nil
+		case string:
+			return x, nil
+		}
+		return nil, fmt.Errorf("无法转换为时间: %T", v)
+	}
+}
+
+// convertBinary 二进制列原样保留字节
+func convertBinary(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case []byte:
+		return x, nil
+	case string:
+		return []byte(x), nil
+	}
+	return nil, fmt.Errorf("无法转换为二进制: %T", v)
+}
+
+// convertText 文本列的 []byte 按字符串写入
+func convertText(v interface{}) (interface{}, error) {
+	if b, ok := v.([]byte); ok {
+		return string(b), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DeadLetter 记录无法写入 MySQL 的坏行
+// 每张表对应一个 JSON Lines 文件，每行包含行数据和 MySQL 返回的错误
+type DeadLetter struct {
+	mu     sync.Mutex
+	path   string
+	budget int // 允许写入死信的最大行数，超过后整表失败
+	count  int
+	file   *os.File
+	enc    *json.Encoder
+}
+
+// deadLetterRecord 死信文件中的一条记录
+type deadLetterRecord struct {
+	Table string                 `json:"table"`
+	Time  string                 `json:"time"`
+	Errno uint16                 `json:"errno,omitempty"`
+	Error string                 `json:"error"`
+	Row   map[string]interface{} `json:"row"`
+}
+
+// NewDeadLetter 创建表的死信记录器，文件为 <dir>/<表名>.jsonl，在第一次写入时才创建
+func NewDeadLetter(dir, tableName string, budget int) *DeadLetter {
+	return &DeadLetter{
+		path:   filepath.Join(dir, tableName+".jsonl"),
+		budget: budget,
+	}
+}
+
+// Path 返回死信文件路径
+func (d *DeadLetter) Path() string {
+	return d.path
+}
+
+// Count 返回已写入死信的行数
+func (d *DeadLetter) Count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// Write 记录一行坏数据，超出错误预算时返回错误
+func (d *DeadLetter) Write(tableName string, columns []MySQLColumn, row []interface{}, cause error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		d.file = f
+		d.enc = json.NewEncoder(f)
+	}
+
+	rec := deadLetterRecord{
+		Table: tableName,
+		Time:  time.Now().Format(time.RFC3339),
+		Error: cause.Error(),
+		Row:   make(map[string]interface{}, len(columns)),
+	}
+	var me *mysql.MySQLError
+	if errors.As(cause, &me) {
+		rec.Errno = me.Number
+		rec.Error = me.Message
+	}
+	for i, col := range columns {
+		rec.Row[col.Name] = row[i]
+	}
+	if err := d.enc.Encode(rec); err != nil {
+		return err
+	}
+
+	d.count++
+	if d.count > d.budget {
+		return fmt.Errorf("坏行数 %d 超过错误预算 %d，详见 %s", d.count, d.budget, d.path)
+	}
+	return nil
+}
+
+// Close 关闭死信文件
+func (d *DeadLetter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// isRowLevelError 判断错误是否可能由个别行的数据引起
+// 只有服务端返回的 SQL 错误才值得二分定位；死锁、锁等待、连接断开等与数据无关的错误直接返回
+func isRowLevelError(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && ClassifyError(err) == ErrorClassOther
+}
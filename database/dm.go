@@ -14,6 +14,8 @@ type DMConnector struct {
 	db *sql.DB
 	// 缓存表名映射，键为小写的表名，值为真实的表名
 	tableNameMap map[string]string
+	// 一致性快照的 LSN，非 0 时迁移读取使用闪回查询
+	snapshotLSN int64
 }
 
 // DMColumn 定义达梦列元数据结构 (与 MySQL 中的 Column 结构体保持一致)
@@ -86,6 +88,11 @@ func (dmc *DMConnector) getRealTableName(tableName string) string {
 	return tableName
 }
 
+// SetMaxOpenConns 调整连接池最大连接数，分片并发读取时需要更多连接
+func (dmc *DMConnector) SetMaxOpenConns(n int) {
+	dmc.db.SetMaxOpenConns(n)
+}
+
 // Close 关闭数据库连接
 func (dmc *DMConnector) Close() error {
 	return dmc.db.Close()
@@ -248,8 +255,17 @@ func (dmc *DMConnector) GetTableSchema(tableName string) ([]DMColumn, error) {
 	return cols, nil
 }
 
+// CountRows 统计源表的行数
+func (dmc *DMConnector) CountRows(tableName string) (int64, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	var count int64
+	err := dmc.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", dmc.readFrom(realTableName))).Scan(&count)
+	return count, err
+}
+
 // GetTableData 获取表的所有数据，对于大表采用流式处理
-func (dmc *DMConnector) GetTableData(tableName string) (*sql.Rows, error) {
+// cols 为按顺序读取的列，为空时读取全部列
+func (dmc *DMConnector) GetTableData(tableName string, cols []string) (*sql.Rows, error) {
 	// 获取真实的表名
 	realTableName := dmc.getRealTableName(tableName)
 	
@@ -262,6 +278,6 @@ func (dmc *DMConnector) GetTableData(tableName string) (*sql.Rows, error) {
 	log.Printf("📥 开始读取表 %s 的数据", tableName)
 	
 	// 对于包含大字段的表，增加流控以避免内存溢出
-	query := fmt.Sprintf("SELECT * FROM %s", realTableName)
+	query := fmt.Sprintf("SELECT %s FROM %s", selectList(cols), dmc.readFrom(realTableName))
 	return dmc.db.Query(query)
 }
\ No newline at end of file
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// rowIDKey 无合适主键时使用达梦 ROWID 伪列分片
+const rowIDKey = "ROWID"
+
+// ChunkRange 表的一个分片: 分片键在 [Lo, Hi] 闭区间内的行
+type ChunkRange struct {
+	Index  int    // 分片序号，从 0 开始
+	Column string // 分片键列名，ROWID 表示按物理行号分片
+	Lo     int64
+	Hi     int64
+}
+
+func (r ChunkRange) String() string {
+	return fmt.Sprintf("#%d %s∈[%d,%d]", r.Index+1, r.Column, r.Lo, r.Hi)
+}
+
+// isIntegerColumn 判断列是否为可放入 int64 的整数类型
+func isIntegerColumn(col DMColumn) bool {
+	t := strings.ToUpper(strings.TrimSpace(col.DataType))
+	switch t {
+	case "BIGINT", "INT", "INTEGER", "SMALLINT", "TINYINT", "BYTE":
+		return true
+	case "NUMBER", "NUMERIC", "DECIMAL", "DEC":
+		return col.DataScale == 0 && col.DataPrecision > 0 && col.DataPrecision <= 18
+	}
+	return false
+}
+
+// chunkKey 选择分片键: 单列整数主键优先，否则使用 ROWID
+func chunkKey(cols []DMColumn) string {
+	var pk []DMColumn
+	for _, col := range cols {
+		if col.IsPrimaryKey {
+			pk = append(pk, col)
+		}
+	}
+	if len(pk) == 1 && isIntegerColumn(pk[0]) {
+		return pk[0].Name
+	}
+	return rowIDKey
+}
+
+// quoteDMIdent 为达梦标识符加双引号，ROWID 伪列保持原样
+func quoteDMIdent(name string) string {
+	if name == rowIDKey {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// PlanChunks 将表按分片键切分为最多 n 个区间
+// quantile 为 false 时按 MIN/MAX 等宽切分；为 true 时用 NTILE 按行数等分，
+// 适合键分布不均匀的表，但需要对全表排序一次。空表返回 nil
+func (dmc *DMConnector) PlanChunks(tableName string, cols []DMColumn, n int, quantile bool) ([]ChunkRange, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	key := chunkKey(cols)
+	keyExpr := quoteDMIdent(key)
+
+	var minKey, maxKey sql.NullInt64
+	err := dmc.db.QueryRow(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", keyExpr, keyExpr, dmc.readFrom(realTableName))).
+		Scan(&minKey, &maxKey)
+	if err != nil {
+		return nil, err
+	}
+	if !minKey.Valid || !maxKey.Valid {
+		return nil, nil
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	var bounds []int64
+	if quantile {
+		bounds, err = dmc.quantileBounds(realTableName, keyExpr, n)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bounds = rangeBounds(minKey.Int64, maxKey.Int64, n)
+	}
+
+	var chunks []ChunkRange
+	lo := minKey.Int64
+	for _, hi := range bounds {
+		if hi < lo {
+			continue
+		}
+		chunks = append(chunks, ChunkRange{Index: len(chunks), Column: key, Lo: lo, Hi: hi})
+		if hi == maxKey.Int64 {
+			break
+		}
+		lo = hi + 1
+	}
+	// NTILE 与 MIN/MAX 不是同一时刻统计的，最后一个分片以 MAX 为准兜底
+	if len(chunks) > 0 {
+		chunks[len(chunks)-1].Hi = maxKey.Int64
+	}
+	return chunks, nil
+}
+
+// rangeBounds 按等宽切分 [min, max]，返回每个分片的上界
+func rangeBounds(min, max int64, n int) []int64 {
+	// 使用无符号运算，避免 max-min 溢出
+	span := uint64(max) - uint64(min)
+	step := span/uint64(n) + 1
+
+	var bounds []int64
+	lo := min
+	for i := 0; i < n; i++ {
+		hi := int64(uint64(lo) + step - 1)
+		if hi < lo || hi > max {
+			hi = max
+		}
+		bounds = append(bounds, hi)
+		if hi == max {
+			break
+		}
+		lo = hi + 1
+	}
+	return bounds
+}
+
+// quantileBounds 用 NTILE 将行数等分为 n 份，返回每份的最大键值
+func (dmc *DMConnector) quantileBounds(realTableName, keyExpr string, n int) ([]int64, error) {
+	query := fmt.Sprintf(`
+		SELECT MAX(K) FROM (
+			SELECT %s AS K, NTILE(%d) OVER (ORDER BY %s) AS B FROM %s
+		) GROUP BY B ORDER BY 1`, keyExpr, n, keyExpr, dmc.readFrom(realTableName))
+	rows, err := dmc.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bounds []int64
+	for rows.Next() {
+		var b int64
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, b)
+	}
+	return bounds, rows.Err()
+}
+
+// GetTableDataRange 读取一个分片的数据
+func (dmc *DMConnector) GetTableDataRange(tableName string, cols []string, r ChunkRange) (*sql.Rows, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s BETWEEN ? AND ?", selectList(cols), dmc.readFrom(realTableName), quoteDMIdent(r.Column))
+	return dmc.db.Query(query, r.Lo, r.Hi)
+}
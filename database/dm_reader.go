@@ -0,0 +1,208 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RowSource 逐行读取的源数据，*sql.Rows 和 ResumableRows 都满足
+type RowSource interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// suspender 可以在长时间暂停前关闭游标、恢复时重新查询的数据源，ResumableRows 满足
+type suspender interface {
+	Suspend()
+}
+
+// suspendIfClosed 时间窗口已关闭时释放 rows 的游标，避免暂停期间长时间占用源库的游标和快照
+// 不支持续读的数据源保持打开
+func suspendIfClosed(s *Schedule, rows RowSource) {
+	if sr, ok := rows.(suspender); ok && s.Closed(time.Now()) {
+		sr.Suspend()
+	}
+}
+
+// ReadRetry 读取中途出错后重新查询的参数
+type ReadRetry struct {
+	Attempts int           // 连续出错时最多重新查询的次数，0 表示不重试
+	Delay    time.Duration // 第一次重新查询前的等待时间，之后每次翻倍，最长 1 分钟
+}
+
+// ResumableRows 按键升序读取源表，读取中途出错 (连接断开、游标超时等) 时重新查询，从已交出的最后一个键之后继续
+// 连续出错超过 ReadRetry.Attempts 次才返回错误，期间每成功读出一行就重新计数
+type ResumableRows struct {
+	dmc   *DMConnector
+	table string
+	cols  []string
+	key   string
+	chunk *ChunkRange
+	retry ReadRetry
+
+	rows     *sql.Rows
+	keyIdx   int    // 键列在结果集中的位置
+	last     *int64 // 已交给调用方的最后一行的键值，为空表示还没有读出任何行
+	failures int    // 连续出错的次数
+	resumes  int    // 重新查询成功的次数
+	paused   bool   // 游标已被 Suspend 关闭，下次 Next 时重新查询
+	err      error
+}
+
+// GetTableDataResumable 与 GetTableDataAfter 相同，按键升序读取键值大于 after 的数据，读取中途出错时按 retry 重新查询
+// key 须为 ResumeKey 返回的整数键列且包含在 cols 中，否则无法记录读取位置，直接返回错误
+func (dmc *DMConnector) GetTableDataResumable(tableName string, cols []string, key string, after *int64, r *ChunkRange, retry ReadRetry) (*ResumableRows, error) {
+	rr := &ResumableRows{dmc: dmc, table: tableName, cols: cols, key: key, chunk: r, retry: retry, keyIdx: -1}
+	if after != nil {
+		last := *after
+		rr.last = &last
+	}
+	for i, c := range cols {
+		if strings.EqualFold(c, key) {
+			rr.keyIdx = i
+		}
+	}
+	if rr.keyIdx < 0 {
+		return nil, fmt.Errorf("键列 %s 不在读取的列中，无法续读", key)
+	}
+	rows, err := rr.query()
+	if err != nil {
+		return nil, err
+	}
+	rr.rows = rows
+	return rr, nil
+}
+
+func (rr *ResumableRows) query() (*sql.Rows, error) {
+	return rr.dmc.GetTableDataAfter(rr.table, rr.cols, rr.key, rr.last, rr.chunk)
+}
+
+// Next 读取下一行，出错时关闭当前结果集并从最后一个键之后重新查询
+func (rr *ResumableRows) Next() bool {
+	if rr.err != nil {
+		return false
+	}
+	if rr.paused {
+		rr.paused = false
+		rows, err := rr.query()
+		if err != nil {
+			rows, err = rr.reopen(err)
+		}
+		if err != nil {
+			rr.err = err
+			return false
+		}
+		rr.rows = rows
+		log.Printf("▶️  表 %s 已重新查询，%s继续读取", rr.table, rr.position())
+	}
+	for {
+		if rr.rows.Next() {
+			return true
+		}
+		err := rr.rows.Err()
+		if err == nil {
+			return false
+		}
+		rr.rows.Close()
+		rows, err := rr.reopen(err)
+		if err != nil {
+			rr.err = err
+			return false
+		}
+		rr.rows = rows
+	}
+}
+
+// reopen 等待后重新查询，直到成功或连续出错的次数用完
+func (rr *ResumableRows) reopen(cause error) (*sql.Rows, error) {
+	for {
+		rr.failures++
+		if rr.failures > rr.retry.Attempts {
+			return nil, fmt.Errorf("读取中途出错，已连续重新查询 %d 次仍失败: %v", rr.retry.Attempts, cause)
+		}
+		delay := RetryPolicy{BaseDelay: rr.retry.Delay, MaxDelay: time.Minute}.backoff(rr.failures)
+		log.Printf("⚠️  读取表 %s 中途出错，%v 后%s重新查询 (%d/%d): %v",
+			rr.table, delay.Round(time.Millisecond), rr.position(), rr.failures, rr.retry.Attempts, cause)
+		time.Sleep(delay)
+
+		rows, err := rr.query()
+		if err == nil {
+			rr.resumes++
+			log.Printf("♻️  表 %s 已重新查询，%s继续读取", rr.table, rr.position())
+			return rows, nil
+		}
+		cause = err
+	}
+}
+
+// position 描述当前读到的位置，用于日志
+func (rr *ResumableRows) position() string {
+	if rr.last == nil {
+		return "从头"
+	}
+	return fmt.Sprintf("从键 %s > %d 处", rr.key, *rr.last)
+}
+
+// Scan 读出当前行并记录它的键值，dest 中键列的容器须为 *interface{}、*int64 或 *sql.RawBytes
+func (rr *ResumableRows) Scan(dest ...interface{}) error {
+	if err := rr.rows.Scan(dest...); err != nil {
+		return err
+	}
+	if rr.keyIdx >= len(dest) {
+		return fmt.Errorf("读取表 %s 时缺少键列 %s 的容器", rr.table, rr.key)
+	}
+	var v interface{}
+	switch d := dest[rr.keyIdx].(type) {
+	case *interface{}:
+		v = *d
+	case *int64:
+		v = *d
+	case *sql.RawBytes:
+		v = []byte(*d)
+	}
+	key, ok := keyToInt64(v)
+	if !ok {
+		// 无法确定键值时也无法续读和记录检查点，不能在出错时才发现
+		return fmt.Errorf("无法解析表 %s 键列 %s 的值 %v", rr.table, rr.key, v)
+	}
+	rr.last = &key
+	rr.failures = 0
+	return nil
+}
+
+// Suspend 关闭当前游标，下次 Next 时从已交出的最后一个键之后重新查询
+// 已交出的行已在批次中，由写入端照常提交，重新查询不会重复或遗漏
+func (rr *ResumableRows) Suspend() {
+	if rr.paused || rr.err != nil {
+		return
+	}
+	rr.rows.Close()
+	rr.paused = true
+	log.Printf("⏸️  表 %s 暂停期间关闭读取游标，恢复后%s重新查询", rr.table, rr.position())
+}
+
+// Err 返回读取过程中无法恢复的错误
+func (rr *ResumableRows) Err() error {
+	if rr.err != nil {
+		return rr.err
+	}
+	if rr.paused {
+		return nil
+	}
+	return rr.rows.Err()
+}
+
+// Close 关闭当前结果集
+func (rr *ResumableRows) Close() error {
+	return rr.rows.Close()
+}
+
+// Resumes 返回读取中途出错后重新查询成功的次数
+func (rr *ResumableRows) Resumes() int {
+	return rr.resumes
+}
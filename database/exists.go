@@ -0,0 +1,27 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OnExistsPolicy 目标表已存在时的处理策略
+type OnExistsPolicy string
+
+const (
+	OnExistsDrop     OnExistsPolicy = "drop"     // 删除后重建 (默认行为)
+	OnExistsTruncate OnExistsPolicy = "truncate" // 保留表结构，清空数据后导入
+	OnExistsAppend   OnExistsPolicy = "append"   // 保留表结构和数据，直接追加
+	OnExistsSkip     OnExistsPolicy = "skip"     // 跳过该表
+	OnExistsFail     OnExistsPolicy = "fail"     // 视为错误，该表迁移失败
+)
+
+// ParseOnExistsPolicy 解析 on-exists 策略字符串
+func ParseOnExistsPolicy(s string) (OnExistsPolicy, error) {
+	switch p := OnExistsPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case OnExistsDrop, OnExistsTruncate, OnExistsAppend, OnExistsSkip, OnExistsFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("未知的 on-exists 策略 %q (可选: drop, truncate, append, skip, fail)", s)
+	}
+}
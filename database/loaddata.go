@@ -0,0 +1,273 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Loader 数据导入方式
+type Loader string
+
+const (
+	LoaderInsert   Loader = "insert"    // 多行 INSERT 语句 (默认)
+	LoaderLoadData Loader = "load-data" // LOAD DATA LOCAL INFILE 流式导入
+)
+
+// ParseLoader 解析导入方式字符串
+func ParseLoader(s string) (Loader, error) {
+	switch l := Loader(strings.ToLower(strings.TrimSpace(s))); l {
+	case LoaderInsert, LoaderLoadData:
+		return l, nil
+	default:
+		return "", fmt.Errorf("未知的导入方式 %q (可选: insert, load-data)", s)
+	}
+}
+
+// readerSeq 用于生成唯一的 Reader:: 名称
+var readerSeq int64
+
+// localInfileEnabled 查询服务端是否允许 LOAD DATA LOCAL
+func (mc *MySQLConnector) localInfileEnabled() (bool, error) {
+	var enabled int
+	if err := mc.db.QueryRow("SELECT @@GLOBAL.local_infile").Scan(&enabled); err != nil {
+		return false, err
+	}
+	return enabled == 1, nil
+}
+
+// canLoadData 判断本次导入能否走 LOAD DATA，不能时返回原因
+func (mc *MySQLConnector) canLoadData(opts InsertOptions) (bool, string) {
+	if opts.WriteMode == WriteModeUpsert {
+		return false, "LOAD DATA 不支持 ON DUPLICATE KEY UPDATE"
+	}
+	enabled, err := mc.localInfileEnabled()
+	if err != nil {
+		return false, fmt.Sprintf("查询 local_infile 失败: %v", err)
+	}
+	if !enabled {
+		return false, "服务端 local_infile=OFF"
+	}
+	return true, ""
+}
+
+// isLocalInfileRefused 判断错误是否为服务端拒绝 LOCAL INFILE
+func isLocalInfileRefused(err error) bool {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return false
+	}
+	// 1148: The used command is not allowed with this MySQL version
+	// 3948: Loading local data is disabled; this must be enabled on both the client and server sides
+	return me.Number == 1148 || me.Number == 3948
+}
+
+// loadData 将源数据编码为 TSV，经 go-sql-driver 的 Reader 处理器流式送入 LOAD DATA LOCAL INFILE
+// 服务端拒绝 LOCAL INFILE 且尚未读取任何行时 fallback 返回 true，调用方可改用 INSERT
+func (mc *MySQLConnector) loadData(tableName string, columns []MySQLColumn, rows RowSource, opts InsertOptions) (stats InsertStats, fallback bool, err error) {
+	name := fmt.Sprintf("dm2mysql_%s_%d", tableName, atomic.AddInt64(&readerSeq, 1))
+
+	// 只有服务端真正请求文件内容时才开始读取源数据，服务端直接拒绝时结果集保持原样
+	var started int32
+	var rowCount int64
+	var offloaded []OffloadEntry // 整表一条语句，语句成功后才记入外存清单
+	produced := make(chan error, 1)
+	mysql.RegisterReaderHandler(name, func() io.Reader {
+		atomic.StoreInt32(&started, 1)
+		pr, pw := io.Pipe()
+		go func() {
+			n, entries, err := mc.writeTSV(pw, columns, rows, opts)
+			atomic.StoreInt64(&rowCount, n)
+			offloaded = entries
+			pw.CloseWithError(err)
+			produced <- err
+		}()
+		return pr
+	})
+	defer mysql.DeregisterReaderHandler(name)
+
+	stmt := mc.loadDataSQL(name, tableName, columns, opts.WriteMode)
+	log.Printf("📤 正在通过 LOAD DATA 导入表 %s", tableName)
+
+	// 单条语句传输整张表，不设超时，也不能重试；取消时驱动断开连接，服务端回滚整条语句
+	conn, err := mc.db.Conn(context.Background())
+	if err != nil {
+		return stats, false, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	result, err := conn.ExecContext(opts.ctx(), stmt)
+	if atomic.LoadInt32(&started) == 1 {
+		// 等待编码协程结束，避免其继续读取已关闭的结果集
+		if perr := <-produced; perr != nil && err == nil {
+			err = perr
+		}
+	}
+	stats.Rows = atomic.LoadInt64(&rowCount)
+	if err != nil {
+		if atomic.LoadInt32(&started) == 0 && isLocalInfileRefused(err) {
+			return stats, true, nil
+		}
+		if opts.ctx().Err() != nil {
+			return stats, false, abortedError(opts.ctx())
+		}
+		return stats, false, fmt.Errorf("load data error: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return stats, false, err
+	}
+	if err := opts.Offload.Record(offloaded); err != nil {
+		return stats, false, err
+	}
+	// LOCAL 模式下服务端无法中途终止传输，重复键的行会被跳过而不是报错，
+	// 因此 insert 方式按 ignore 统计，被跳过的行计入忽略行数
+	mode := opts.WriteMode
+	if mode == WriteModeInsert {
+		mode = WriteModeIgnore
+	}
+	mode.account(&stats, stats.Rows, affected)
+	if opts.DeadLetter != nil && stats.Ignored > 0 {
+		log.Printf("⚠️  表 %s 有 %d 行因键冲突被跳过，LOAD DATA 不会将其写入死信文件", tableName, stats.Ignored)
+	}
+	log.Printf("📥 表 %s LOAD DATA 完成 (%d 行, 耗时 %v)", tableName, stats.Rows, time.Since(start).Round(time.Millisecond))
+	return stats, false, nil
+}
+
+// loadDataSQL 生成 LOAD DATA 语句
+// 二进制列以十六进制传输，再通过 UNHEX 还原，避免字节在字符集转换中被破坏
+func (mc *MySQLConnector) loadDataSQL(readerName, tableName string, columns []MySQLColumn, mode WriteMode) string {
+	charset := "utf8"
+	if mc.version >= 8 {
+		charset = "utf8mb4"
+	}
+
+	var onDup string
+	switch mode {
+	case WriteModeReplace:
+		onDup = " REPLACE"
+	case WriteModeIgnore:
+		onDup = " IGNORE"
+	}
+
+	targets := make([]string, len(columns))
+	var sets []string
+	for i, col := range columns {
+		if isBinaryColumn(col, mc.version) {
+			targets[i] = fmt.Sprintf("@c%d", i)
+			sets = append(sets, fmt.Sprintf("`%s` = UNHEX(@c%d)", col.Name, i))
+		} else {
+			targets[i] = "`" + col.Name + "`"
+		}
+	}
+
+	stmt := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s'%s INTO TABLE `%s` CHARACTER SET %s "+
+		`FIELDS TERMINATED BY '\t' ESCAPED BY '\\' LINES TERMINATED BY '\n' (%s)`,
+		readerName, onDup, tableName, charset, strings.Join(targets, ", "))
+	if len(sets) > 0 {
+		stmt += " SET " + strings.Join(sets, ", ")
+	}
+	return stmt
+}
+
+// isBinaryColumn 判断列映射后是否为二进制类型
+func isBinaryColumn(col MySQLColumn, version int) bool {
+	return strings.Contains(convertDMTypeToMySQL(col, version), "BLOB")
+}
+
+// tsvEscaper LOAD DATA 默认 ESCAPED BY '\\' 下需要转义的字符
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// writeTSV 逐行读取源数据并写出 TSV，返回写出的行数和外存列的清单记录
+func (mc *MySQLConnector) writeTSV(w io.Writer, columns []MySQLColumn, rows RowSource, opts InsertOptions) (int64, []OffloadEntry, error) {
+	bw := bufio.NewWriterSize(w, 256*1024)
+	binary := make([]bool, len(columns))
+	for i, col := range columns {
+		binary[i] = isBinaryColumn(col, mc.version)
+	}
+	rc := newRowConverter(columns, mc.version, opts.Offload)
+	// 读出的每一行直接写入，读写限速同时生效
+	throttle := append(append(Throttle(nil), opts.ReadLimit...), opts.WriteLimit...)
+
+	values := make([]interface{}, rc.width())
+	scanArgs := make([]interface{}, rc.width())
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return n, nil, fmt.Errorf("scan rows error: %v", err)
+		}
+		row, err := rc.convert(values)
+		if err != nil {
+			return n, nil, fmt.Errorf("第 %d 行: %v", n+1, err)
+		}
+		throttle.Wait(opts.ctx(), 1, estimateRowBytes(row))
+		if opts.ctx().Err() != nil {
+			return n, nil, abortedError(opts.ctx())
+		}
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			bw.WriteString(tsvField(v, binary[i]))
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return n, nil, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, nil, fmt.Errorf("read rows error: %v", err)
+	}
+	return n, rc.takeOffloaded(), bw.Flush()
+}
+
+// tsvField 将单个值编码为 TSV 字段，NULL 编码为 \N
+func tsvField(v interface{}, binary bool) string {
+	if v == nil {
+		return `\N`
+	}
+	if binary {
+		switch b := v.(type) {
+		case []byte:
+			return hex.EncodeToString(b)
+		case string:
+			return hex.EncodeToString([]byte(b))
+		}
+	}
+
+	switch x := v.(type) {
+	case []byte:
+		return tsvEscaper.Replace(string(x))
+	case string:
+		return tsvEscaper.Replace(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999")
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	default:
+		return tsvEscaper.Replace(fmt.Sprint(x))
+	}
+}
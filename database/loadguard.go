@@ -0,0 +1,364 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LagSource 读取复制延迟的方式
+type LagSource string
+
+const (
+	LagSourceStatus            LagSource = "status"             // SHOW REPLICA STATUS 的 Seconds_Behind_Source
+	LagSourcePerformanceSchema LagSource = "performance_schema" // 按工作线程正在应用的事务的原始提交时间计算，需要 MySQL 8.0
+)
+
+// ParseLagSource 解析复制延迟的读取方式
+func ParseLagSource(s string) (LagSource, error) {
+	switch src := LagSource(strings.ToLower(strings.TrimSpace(s))); src {
+	case LagSourceStatus, LagSourcePerformanceSchema:
+		return src, nil
+	}
+	return "", fmt.Errorf("未知的复制延迟读取方式 %q (可选: status, performance_schema)", s)
+}
+
+// LoadGuardOptions 负载保护参数，阈值为 0 表示不检查该项
+type LoadGuardOptions struct {
+	ReplicaDSNs       []string      // 从库 DSN
+	LagSource         LagSource     // 复制延迟的读取方式
+	MaxReplicaLag     time.Duration // 任一从库延迟超过该值时暂停写入
+	MaxThreadsRunning int64         // 主库 Threads_running 超过该值时暂停写入
+	Interval          time.Duration // 检查间隔
+}
+
+// guardState 负载保护状态
+type guardState int
+
+const (
+	guardNormal guardState = iota // 正常写入
+	guardSlow                     // 超过阈值的一半，每批写入前按超出比例等待
+	guardPaused                   // 超过阈值，暂停写入
+)
+
+// LoadGuard 定期检查从库复制延迟和主库 Threads_running，负载过高时让写入协程减速或暂停
+// 指标超过阈值时暂停，降到阈值一半以下才恢复，避免在阈值附近反复切换
+type LoadGuard struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	hosts    []string // 从库地址，用于日志
+	opts     LoadGuardOptions
+
+	mu      sync.Mutex
+	state   guardState
+	ratio   float64       // 各指标相对阈值的最大比例
+	resumed chan struct{} // 暂停期间有效，恢复时关闭
+	pauses  int
+	paused  time.Duration
+	since   time.Time // 本次暂停的开始时间
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLoadGuard 连接从库并启动后台检查，阈值都为 0 时返回 nil
+func NewLoadGuard(primary *MySQLConnector, opts LoadGuardOptions) (*LoadGuard, error) {
+	if opts.MaxReplicaLag <= 0 && opts.MaxThreadsRunning <= 0 {
+		return nil, nil
+	}
+	if opts.MaxReplicaLag > 0 && len(opts.ReplicaDSNs) == 0 {
+		return nil, fmt.Errorf("检查复制延迟需要指定从库 DSN")
+	}
+	if opts.LagSource == "" {
+		opts.LagSource = LagSourceStatus
+	}
+
+	g := &LoadGuard{primary: primary.db, opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	if opts.MaxReplicaLag > 0 {
+		for _, dsn := range opts.ReplicaDSNs {
+			db, err := sql.Open("mysql", dsn)
+			if err != nil {
+				g.closeReplicas()
+				return nil, err
+			}
+			db.SetMaxOpenConns(1)
+			db.SetMaxIdleConns(1)
+			g.replicas = append(g.replicas, db)
+			g.hosts = append(g.hosts, dsnHost(dsn))
+			if err := db.Ping(); err != nil {
+				g.closeReplicas()
+				return nil, fmt.Errorf("连接从库 %s 失败: %v", dsnHost(dsn), err)
+			}
+		}
+	}
+
+	g.check()
+	go g.loop()
+	return g, nil
+}
+
+// dsnHost 从 DSN 中取出地址部分，日志中不输出密码
+func dsnHost(dsn string) string {
+	if i := strings.LastIndex(dsn, "@"); i >= 0 {
+		dsn = dsn[i+1:]
+	}
+	if i := strings.Index(dsn, "/"); i >= 0 {
+		dsn = dsn[:i]
+	}
+	return dsn
+}
+
+func (g *LoadGuard) loop() {
+	defer close(g.done)
+	ticker := time.NewTicker(g.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.check()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// check 读取各项指标并更新状态，读取失败的指标不参与本次判断
+func (g *LoadGuard) check() {
+	var ratio float64
+	var reasons []string
+	measured := false
+
+	if g.opts.MaxReplicaLag > 0 {
+		for i, db := range g.replicas {
+			lag, err := replicaLag(db, g.opts.LagSource)
+			if err != nil {
+				log.Printf("⚠️  读取从库 %s 的复制延迟失败: %v", g.hosts[i], err)
+				continue
+			}
+			measured = true
+			r := float64(lag) / float64(g.opts.MaxReplicaLag)
+			ratio = max(ratio, r)
+			if r >= 0.5 {
+				reasons = append(reasons, fmt.Sprintf("从库 %s 延迟 %v (阈值 %v)", g.hosts[i], lag.Round(time.Second), g.opts.MaxReplicaLag))
+			}
+		}
+	}
+	if g.opts.MaxThreadsRunning > 0 {
+		n, err := threadsRunning(g.primary)
+		if err != nil {
+			log.Printf("⚠️  读取主库 Threads_running 失败: %v", err)
+		} else {
+			measured = true
+			r := float64(n) / float64(g.opts.MaxThreadsRunning)
+			ratio = max(ratio, r)
+			if r >= 0.5 {
+				reasons = append(reasons, fmt.Sprintf("主库 Threads_running %d (阈值 %d)", n, g.opts.MaxThreadsRunning))
+			}
+		}
+	}
+	if !measured {
+		// 所有指标都读取失败时保持原状态
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ratio = ratio
+	next := guardNormal
+	switch {
+	case ratio > 1:
+		next = guardPaused
+	case g.state == guardPaused && ratio >= 0.5:
+		next = guardPaused
+	case ratio >= 0.5:
+		next = guardSlow
+	}
+	if next == g.state {
+		return
+	}
+
+	reason := strings.Join(reasons, ", ")
+	switch next {
+	case guardPaused:
+		g.resumed = make(chan struct{})
+		g.since = time.Now()
+		g.pauses++
+		log.Printf("⏸️  目标库负载过高，暂停写入: %s", reason)
+	case guardSlow:
+		if g.state == guardPaused {
+			g.resume()
+		}
+		log.Printf("🐢 目标库负载偏高，写入减速: %s", reason)
+	case guardNormal:
+		if g.state == guardPaused {
+			g.resume()
+		} else {
+			log.Println("▶️  目标库负载恢复，正常写入")
+		}
+	}
+	g.state = next
+}
+
+// resume 结束暂停，调用方持有锁
+func (g *LoadGuard) resume() {
+	d := time.Since(g.since)
+	g.paused += d
+	close(g.resumed)
+	log.Printf("▶️  恢复写入 (本次暂停 %v)", d.Round(time.Second))
+}
+
+// Wait 每批写入前调用: 暂停时等到恢复，减速时按超出阈值一半的比例等待最多一个检查间隔
+// 返回等待的时间，g 为空时不等待
+func (g *LoadGuard) Wait(ctx context.Context) (time.Duration, error) {
+	if g == nil {
+		return 0, nil
+	}
+	start := time.Now()
+	for {
+		g.mu.Lock()
+		state, ratio, resumed := g.state, g.ratio, g.resumed
+		g.mu.Unlock()
+
+		switch state {
+		case guardPaused:
+			select {
+			case <-resumed:
+				continue
+			case <-ctx.Done():
+				return time.Since(start), ctx.Err()
+			}
+		case guardSlow:
+			delay := time.Duration((ratio - 0.5) * 2 * float64(g.opts.Interval))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return time.Since(start), ctx.Err()
+			}
+		}
+		return time.Since(start), nil
+	}
+}
+
+// Pauses 返回暂停次数和累计暂停时间
+func (g *LoadGuard) Pauses() (int, time.Duration) {
+	if g == nil {
+		return 0, 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	d := g.paused
+	if g.state == guardPaused {
+		d += time.Since(g.since)
+	}
+	return g.pauses, d
+}
+
+// Close 停止后台检查，正在暂停的写入协程随即恢复
+func (g *LoadGuard) Close() {
+	if g == nil {
+		return
+	}
+	close(g.stop)
+	<-g.done
+	g.mu.Lock()
+	if g.state == guardPaused {
+		g.resume()
+	}
+	g.state = guardNormal
+	g.mu.Unlock()
+	g.closeReplicas()
+}
+
+func (g *LoadGuard) closeReplicas() {
+	for _, db := range g.replicas {
+		db.Close()
+	}
+}
+
+// replicaLag 读取从库的复制延迟
+func replicaLag(db *sql.DB, source LagSource) (time.Duration, error) {
+	if source == LagSourcePerformanceSchema {
+		// 空闲的工作线程没有正在应用的事务，不计延迟
+		var us sql.NullInt64
+		err := db.QueryRow(`
+			SELECT MAX(IF(APPLYING_TRANSACTION = '', 0,
+				TIMESTAMPDIFF(MICROSECOND, APPLYING_TRANSACTION_ORIGINAL_COMMIT_TIMESTAMP, NOW(6))))
+			FROM performance_schema.replication_applier_status_by_worker`).Scan(&us)
+		if err != nil {
+			return 0, err
+		}
+		if !us.Valid {
+			return 0, fmt.Errorf("不是从库")
+		}
+		return time.Duration(max(us.Int64, 0)) * time.Microsecond, nil
+	}
+
+	// 8.0.22 起为 SHOW REPLICA STATUS，之前的版本只支持 SHOW SLAVE STATUS
+	rows, err := db.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = db.Query("SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	idx := -1
+	for i, c := range cols {
+		if c == "Seconds_Behind_Source" || c == "Seconds_Behind_Master" {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("复制状态中没有 Seconds_Behind_Source 列")
+	}
+
+	// 多源复制时每个通道一行，取最大值
+	values := make([]sql.RawBytes, len(cols))
+	scanArgs := make([]interface{}, len(cols))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	var lag int64
+	found := false
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return 0, err
+		}
+		if values[idx] == nil {
+			return 0, fmt.Errorf("复制线程未运行")
+		}
+		n, err := strconv.ParseInt(string(values[idx]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		lag = max(lag, n)
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("不是从库")
+	}
+	return time.Duration(lag) * time.Second, nil
+}
+
+// threadsRunning 读取主库当前的 Threads_running
+func threadsRunning(db *sql.DB) (int64, error) {
+	var name string
+	var n int64
+	err := db.QueryRow("SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &n)
+	return n, err
+}
@@ -0,0 +1,474 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LOBSource 读取源表单行的大字段
+type LOBSource interface {
+	// OpenLOBs 查询主键为 key 的行的大字段列，返回按列顺序排列的定位器，用完后需要 Close
+	OpenLOBs(tableName string, columns, keyCols []string, key []interface{}) (LOBRow, error)
+}
+
+// LOBRow 单行大字段的定位器，按块读取时不需要把整个值读入内存
+type LOBRow interface {
+	// Length 返回第 i 个大字段的长度，BLOB 为字节数，CLOB 为字符数；值为 NULL 时返回 -1
+	Length(i int) (int64, error)
+	// Read 读取第 i 个大字段从 offset (从 1 开始) 起的最多 n 个字节 (BLOB) 或字符 (CLOB)
+	Read(i int, offset, n int64) (interface{}, error)
+	Close() error
+}
+
+// LOBStream 大字段分块流式导入的参数
+// 开启后源表只读取非大字段列，每行的大字段通过定位器按块回源读取，攒成片段后首段随行插入，其余片段逐段追加，
+// 内存中最多同时保留一个片段 (不超过 max_allowed_packet 的一半) 和一块
+type LOBStream struct {
+	Source    LOBSource
+	Table     string // 源表名
+	ChunkSize int64  // 每次从定位器读取的字节数 (BLOB) 或字符数 (CLOB)
+}
+
+// IsLOBType 判断达梦类型是否为大字段
+func IsLOBType(dataType string) bool {
+	t := strings.ToUpper(strings.TrimSpace(dataType))
+	return strings.Contains(t, "LOB") || t == "IMAGE" || t == "TEXT" || t == "LONGVARCHAR" || t == "LONGVARBINARY"
+}
+
+// ReadColumns 返回需要从源表读取的列，开启分块导入时不含大字段列；s 为空时返回全部列
+func (s *LOBStream) ReadColumns(cols []MySQLColumn) []string {
+	if s == nil {
+		return ColumnNames(cols)
+	}
+	plain, _ := splitLOBColumns(cols)
+	return ColumnNames(plain)
+}
+
+// splitLOBColumns 按原有顺序拆分普通列和大字段列
+func splitLOBColumns(cols []MySQLColumn) (plain, lobs []MySQLColumn) {
+	for _, col := range cols {
+		if IsLOBType(col.DataType) && !col.Offload {
+			lobs = append(lobs, col)
+		} else {
+			plain = append(plain, col)
+		}
+	}
+	return plain, lobs
+}
+
+// dmBlob 达梦驱动 *dm.DmBlob 的定位器读取方法，位置从 1 开始
+type dmBlob interface {
+	GetLength() (int64, error)
+	GetBytes(pos int64, length int32) ([]byte, error)
+}
+
+// dmClob 达梦驱动 *dm.DmClob 的定位器读取方法，位置和长度按字符计
+type dmClob interface {
+	GetLength() (int64, error)
+	GetSubString(pos int64, length int32) (string, error)
+}
+
+// dmLOBRow 保持查询游标打开，定位器在游标关闭前有效
+type dmLOBRow struct {
+	rows   *sql.Rows
+	values []interface{}
+}
+
+// OpenLOBs 按主键查询一行的大字段列，驱动返回定位器，各块通过定位器向服务端按需读取
+// 设置了快照点时同样通过闪回查询读取快照版本
+func (dmc *DMConnector) OpenLOBs(tableName string, columns, keyCols []string, key []interface{}) (LOBRow, error) {
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		cols[i] = quoteDMIdent(c)
+	}
+	conds := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		conds[i] = quoteDMIdent(k) + " = ?"
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(cols, ", "), dmc.readFrom(dmc.getRealTableName(tableName)), strings.Join(conds, " AND "))
+
+	rows, err := dmc.db.Query(query, key...)
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		err := rows.Err()
+		rows.Close()
+		if err == nil {
+			err = fmt.Errorf("源表中已找不到该行")
+		}
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	for i, v := range values {
+		// 驱动已经把值整体读出时按字符截取，先转换一次避免每块都重新解码
+		if str, ok := v.(string); ok {
+			values[i] = []rune(str)
+		}
+	}
+	return &dmLOBRow{rows: rows, values: values}, nil
+}
+
+func (r *dmLOBRow) Length(i int) (int64, error) {
+	switch v := r.values[i].(type) {
+	case nil:
+		return -1, nil
+	case dmClob:
+		return v.GetLength()
+	case dmBlob:
+		return v.GetLength()
+	case []byte:
+		return int64(len(v)), nil
+	case []rune:
+		return int64(len(v)), nil
+	}
+	return 0, fmt.Errorf("不支持的大字段值类型 %T", r.values[i])
+}
+
+func (r *dmLOBRow) Read(i int, offset, n int64) (interface{}, error) {
+	if n > math.MaxInt32 {
+		n = math.MaxInt32
+	}
+	switch v := r.values[i].(type) {
+	case dmClob:
+		return v.GetSubString(offset, int32(n))
+	case dmBlob:
+		return v.GetBytes(offset, int32(n))
+	case []byte:
+		return v[min(offset-1, int64(len(v))):min(offset-1+n, int64(len(v)))], nil
+	case []rune:
+		return string(v[min(offset-1, int64(len(v))):min(offset-1+n, int64(len(v)))]), nil
+	}
+	return nil, fmt.Errorf("不支持的大字段值类型 %T", r.values[i])
+}
+
+func (r *dmLOBRow) Close() error {
+	return r.rows.Close()
+}
+
+// insertWithLOBs 逐行写入含大字段的表，rows 只包含非大字段列
+// 每行通过定位器按块读取大字段，攒成接近 max_allowed_packet 一半的片段：首段随非大字段列以单行语句插入，
+// 其余片段用 CONCAT 追加，最后核对每个大字段的长度
+// 插入和每次追加各为一个事务，达梦的读取都在事务之外；一行中途失败时删除该行已写入的部分
+func (mc *MySQLConnector) insertWithLOBs(tableName string, columns []MySQLColumn, rows RowSource, opts InsertOptions) (InsertStats, error) {
+	var stats InsertStats
+	ls := opts.LOB
+	plain, lobs := splitLOBColumns(columns)
+
+	var keyCols []string
+	var keyIdx []int
+	for i, col := range plain {
+		if col.IsPrimaryKey {
+			keyCols = append(keyCols, col.Name)
+			keyIdx = append(keyIdx, i)
+		}
+	}
+	if len(keyCols) == 0 {
+		return stats, fmt.Errorf("表 %s 没有主键，无法分块导入大字段", tableName)
+	}
+	ckptIdx := -1
+	if opts.KeyColumn != "" && opts.OnCommit != nil {
+		for i, col := range plain {
+			if col.Name == opts.KeyColumn {
+				ckptIdx = i
+			}
+		}
+	}
+
+	// 插入语句: 普通列在前，大字段列在后
+	ordered := append(append([]MySQLColumn(nil), plain...), lobs...)
+	names := make([]string, len(ordered))
+	holders := make([]string, len(ordered))
+	for i, col := range ordered {
+		names[i] = "`" + col.Name + "`"
+		holders[i] = "?"
+	}
+	insertSQL := fmt.Sprintf("%s `%s` (%s) VALUES (%s)", opts.WriteMode.verb(), tableName, strings.Join(names, ", "), strings.Join(holders, ", "))
+	if opts.WriteMode == WriteModeUpsert {
+		insertSQL += mc.upsertClause(ordered)
+	}
+	keyConds := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		keyConds[i] = "`" + k + "` = ?"
+	}
+	where := strings.Join(keyConds, " AND ")
+	appendSQL := make([]string, len(lobs))
+	lengths := make([]string, len(lobs))
+	lobNames := make([]string, len(lobs))
+	binary := make([]bool, len(lobs))
+	for i, col := range lobs {
+		lobNames[i] = col.Name
+		binary[i] = isBinaryColumn(col, mc.version)
+		appendSQL[i] = fmt.Sprintf("UPDATE `%s` SET `%s` = CONCAT(`%s`, ?) WHERE %s", tableName, col.Name, col.Name, where)
+		// BLOB 按字节、CLOB 按字符，与定位器返回的长度单位一致
+		if binary[i] {
+			lengths[i] = fmt.Sprintf("LENGTH(`%s`)", col.Name)
+		} else {
+			lengths[i] = fmt.Sprintf("CHAR_LENGTH(`%s`)", col.Name)
+		}
+	}
+	verifySQL := fmt.Sprintf("SELECT %s FROM `%s` WHERE %s", strings.Join(lengths, ", "), tableName, where)
+	deleteSQL := fmt.Sprintf("DELETE FROM `%s` WHERE %s", tableName, where)
+
+	// 每次写入的大字段片段不超过 max_allowed_packet 的一半，给转义和语句本身留出余量；
+	// 片段越大追加次数越少，每次追加 CONCAT 都会重写整个值
+	pieceBytes := max(mc.maxPacket/2-4096, 1024)
+	log.Printf("📝 表 %s 分块导入 %d 个大字段列 (每块 %d, 每次写入不超过 %d 字节, 写入方式 %s)", tableName, len(lobs), ls.ChunkSize, pieceBytes, opts.WriteMode)
+
+	rc := newRowConverter(plain, mc.version, opts.Offload)
+	lobConvs := newRowConverter(lobs, mc.version, nil).convs
+	values := make([]interface{}, rc.width())
+	scanArgs := make([]interface{}, rc.width())
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	// 逐行读出后立即写入，读写限速同时生效
+	throttle := append(append(Throttle(nil), opts.ReadLimit...), opts.WriteLimit...)
+	wait := func(rows int64, v ...interface{}) {
+		waited, _ := throttle.Wait(opts.ctx(), rows, estimateRowBytes(v))
+		stats.RateWait += waited
+		waited, _ = opts.Guard.Wait(opts.ctx())
+		stats.GuardWait += waited
+	}
+
+	conn := &pinnedConn{db: mc.writeDB}
+	defer conn.Close()
+	retry := opts.retryPolicy()
+	exec := func(query string, args ...interface{}) (int64, error) {
+		var affected int64
+		retries, err := conn.withRetry(retry, func(tx *sql.Tx) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+			result, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			affected, err = result.RowsAffected()
+			return err
+		})
+		stats.Retries += int64(retries)
+		return affected, err
+	}
+
+	var appended int64
+	readPiece := func(lr LOBRow, i int, offset, total, limit int64) (interface{}, int64, error) {
+		piece, next, err := readLOBPiece(lr, i, offset, total, ls.ChunkSize, limit, binary[i], lobConvs[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("读取大字段 %s 失败: %v", lobs[i].Name, err)
+		}
+		return piece, next, nil
+	}
+
+	// writeRow 写入一行，inserted 表示插入已经提交，之后的步骤失败时需要删除该行
+	writeRow := func(lr LOBRow, row, key []interface{}) (inserted bool, affected int64, err error) {
+		// 各大字段的首段随行插入，所有首段合计不超过一个片段
+		firstBytes := (pieceBytes - estimateRowBytes(row)) / int64(len(lobs))
+		full := make([]interface{}, len(row), len(row)+len(lobs))
+		copy(full, row)
+		totals := make([]int64, len(lobs))
+		next := make([]int64, len(lobs))
+		for i, col := range lobs {
+			total, err := lr.Length(i)
+			if err != nil {
+				return false, 0, fmt.Errorf("读取大字段 %s 的长度失败: %v", col.Name, err)
+			}
+			// CONCAT 的结果超过 max_allowed_packet 时变为 NULL，写入前就能确定放不下的不再写入
+			if total > mc.maxPacket {
+				return false, 0, fmt.Errorf("大字段 %s 长度 %d 超过 MySQL max_allowed_packet (%d 字节)，请调大该参数",
+					col.Name, total, mc.maxPacket)
+			}
+			totals[i] = total
+			var piece interface{}
+			if total >= 0 {
+				if piece, next[i], err = readPiece(lr, i, 1, total, firstBytes); err != nil {
+					return false, 0, err
+				}
+			}
+			full = append(full, piece)
+		}
+
+		wait(1, full...)
+		if affected, err = exec(insertSQL, full...); err != nil {
+			return false, 0, fmt.Errorf("batch exec error: %v", err)
+		}
+		// ignore 模式下被忽略的行保持原样，不追加
+		if opts.WriteMode == WriteModeIgnore && affected == 0 {
+			return false, affected, nil
+		}
+
+		for i, col := range lobs {
+			for totals[i] >= 0 && next[i] <= totals[i] {
+				var piece interface{}
+				if piece, next[i], err = readPiece(lr, i, next[i], totals[i], pieceBytes); err != nil {
+					return true, affected, err
+				}
+				wait(0, piece)
+				if _, err := exec(appendSQL[i], append([]interface{}{piece}, key...)...); err != nil {
+					return true, affected, fmt.Errorf("追加大字段 %s 失败: %w", col.Name, err)
+				}
+				appended++
+			}
+		}
+		return true, affected, mc.verifyLOBLengths(verifySQL, key, lobs, totals)
+	}
+
+	lastReportTime := time.Now()
+
+	for {
+		// 时间窗口外在行之间暂停
+		suspendIfClosed(opts.Schedule, rows)
+		waited, _ := opts.Schedule.Wait(opts.ctx())
+		stats.ScheduleWait += waited
+		// 取消后不再开始新的一行，正在写入的行已经完整写完
+		if opts.ctx().Err() != nil {
+			return stats, abortedError(opts.ctx())
+		}
+		if !rows.Next() {
+			break
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return stats, fmt.Errorf("scan rows error: %v", err)
+		}
+		row, err := rc.convert(values)
+		if err != nil {
+			return stats, fmt.Errorf("表 %s 第 %d 行: %v", tableName, stats.Rows+1, err)
+		}
+		stats.Rows++
+
+		key := make([]interface{}, len(keyIdx))
+		for i, idx := range keyIdx {
+			key[i] = row[idx]
+		}
+		var ckptKey int64
+		if ckptIdx >= 0 {
+			var ok bool
+			if ckptKey, ok = keyToInt64(row[ckptIdx]); !ok {
+				return stats, fmt.Errorf("无法解析键列 %s 的值 %v", opts.KeyColumn, row[ckptIdx])
+			}
+		}
+
+		lr, err := ls.Source.OpenLOBs(ls.Table, lobNames, keyCols, key)
+		if err != nil {
+			return stats, fmt.Errorf("读取表 %s 第 %d 行的大字段失败: %v", tableName, stats.Rows, err)
+		}
+		inserted, affected, err := writeRow(lr, row, key)
+		lr.Close()
+		if err != nil {
+			if inserted {
+				// 已提交的首段和部分追加段不能留在目标表中
+				if _, derr := mc.db.Exec(deleteSQL, key...); derr != nil {
+					log.Printf("⚠️  删除表 %s 第 %d 行写入一半的数据失败，请手工处理: %v", tableName, stats.Rows, derr)
+				} else {
+					log.Printf("🧹 已删除表 %s 第 %d 行写入一半的数据", tableName, stats.Rows)
+				}
+			}
+			return stats, fmt.Errorf("表 %s 第 %d 行: %v", tableName, stats.Rows, err)
+		}
+		opts.WriteMode.account(&stats, 1, affected)
+		if err := opts.Offload.Record(rc.takeOffloaded()); err != nil {
+			return stats, err
+		}
+
+		if ckptIdx >= 0 {
+			opts.OnCommit(ckptKey, 1)
+		}
+		if time.Since(lastReportTime) > 30*time.Second {
+			log.Printf("📊 表 %s 已处理 %d 行 (追加大字段 %d 段)", tableName, stats.Rows, appended)
+			lastReportTime = time.Now()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("read rows error: %v", err)
+	}
+	return stats, nil
+}
+
+// readLOBPiece 从 offset 起按块读取第 i 个大字段，直到读完或片段达到 limit 字节，返回片段和下一块的起点
+// CLOB 按每字符 4 字节估算，保证转换成 utf8mb4 后仍不超过 limit；片段至少包含一块，避免 limit 过小时无法前进
+func readLOBPiece(lr LOBRow, i int, offset, total, chunkSize, limit int64, binary bool, conv columnConverter) (interface{}, int64, error) {
+	unit := int64(4)
+	if binary {
+		unit = 1
+	}
+	piece := []byte{}
+	for offset <= total {
+		n := min(chunkSize, (limit-int64(len(piece)))/unit, total-offset+1)
+		if n <= 0 {
+			if len(piece) > 0 {
+				break
+			}
+			n = min(chunkSize, total-offset+1)
+		}
+		chunk, err := lr.Read(i, offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		// 按实际读到的长度前进，读到空块说明源值在读取期间变短了
+		var got int64
+		switch x := chunk.(type) {
+		case []byte:
+			got = int64(len(x))
+		case string:
+			got = int64(utf8.RuneCountInString(x))
+		}
+		if got == 0 {
+			return nil, 0, fmt.Errorf("位置 %d 读到空块，源值长度与 %d 不一致", offset, total)
+		}
+		offset += got
+		if chunk, err = conv(chunk); err != nil {
+			return nil, 0, err
+		}
+		switch x := chunk.(type) {
+		case []byte:
+			piece = append(piece, x...)
+		case string:
+			piece = append(piece, x...)
+		default:
+			return nil, 0, fmt.Errorf("不支持的大字段值类型 %T", chunk)
+		}
+	}
+	if binary {
+		return piece, offset, nil
+	}
+	return string(piece), offset, nil
+}
+
+// verifyLOBLengths 核对写入后每个大字段的长度与源长度一致
+// CONCAT 的结果超过 max_allowed_packet 时 MySQL 只给出警告并写入 NULL，不核对就会静默丢失数据
+func (mc *MySQLConnector) verifyLOBLengths(verifySQL string, key []interface{}, lobs []MySQLColumn, totals []int64) error {
+	got := make([]sql.NullInt64, len(lobs))
+	dest := make([]interface{}, len(lobs))
+	for i := range got {
+		dest[i] = &got[i]
+	}
+	if err := mc.db.QueryRow(verifySQL, key...).Scan(dest...); err != nil {
+		return fmt.Errorf("核对大字段长度失败: %v", err)
+	}
+	for i, col := range lobs {
+		switch {
+		case totals[i] < 0 && got[i].Valid:
+			return fmt.Errorf("大字段 %s 源值为 NULL，写入后长度为 %d", col.Name, got[i].Int64)
+		case totals[i] >= 0 && !got[i].Valid:
+			return fmt.Errorf("大字段 %s 写入后为 NULL，源长度 %d (追加结果可能超过 max_allowed_packet)", col.Name, totals[i])
+		case totals[i] >= 0 && got[i].Int64 != totals[i]:
+			return fmt.Errorf("大字段 %s 写入后长度 %d 与源长度 %d 不一致", col.Name, got[i].Int64, totals[i])
+		}
+	}
+	return nil
+}
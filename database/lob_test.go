@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingLOBRow 读取到 failAt 位置时返回错误
+type failingLOBRow struct {
+	dmLOBRow
+	failAt int64
+}
+
+func (r *failingLOBRow) Read(i int, offset, n int64) (interface{}, error) {
+	if offset == r.failAt {
+		return nil, errors.New("lost connection")
+	}
+	return r.dmLOBRow.Read(i, offset, n)
+}
+
+func TestReadLOBPiece(t *testing.T) {
+	blob := []byte("0123456789")
+	lr := &dmLOBRow{values: []interface{}{blob, []rune("中文字符abc"), nil}}
+
+	// 按 limit 切成多个片段，每个片段由若干块拼成
+	var pieces []string
+	for offset := int64(1); offset <= 10; {
+		piece, next, err := readLOBPiece(lr, 0, offset, 10, 3, 4, true, convertBinary)
+		if err != nil {
+			t.Fatalf("readLOBPiece: %v", err)
+		}
+		pieces = append(pieces, string(piece.([]byte)))
+		offset = next
+	}
+	if got := strings.Join(pieces, "|"); got != "0123|4567|89" {
+		t.Fatalf("pieces = %q, want 0123|4567|89", got)
+	}
+
+	// CLOB 按字符读取，每字符按 4 字节估算
+	piece, next, err := readLOBPiece(lr, 1, 1, 7, 2, 12, false, convertText)
+	if err != nil || piece != "中文字" || next != 4 {
+		t.Fatalf("readLOBPiece = %q, %d, %v; want 中文字, 4, nil", piece, next, err)
+	}
+
+	// limit 小于一个字符时仍然读取一块，保证能前进
+	piece, next, err = readLOBPiece(lr, 1, 5, 7, 2, 1, false, convertText)
+	if err != nil || piece != "ab" || next != 7 {
+		t.Fatalf("readLOBPiece = %q, %d, %v; want ab, 7, nil", piece, next, err)
+	}
+
+	// 长度为 0 的值得到空片段而不是 NULL
+	piece, next, err = readLOBPiece(lr, 0, 1, 0, 3, 4, true, convertBinary)
+	if b, ok := piece.([]byte); err != nil || !ok || len(b) != 0 || next != 1 {
+		t.Fatalf("readLOBPiece = %#v, %d, %v; want empty []byte", piece, next, err)
+	}
+
+	if n, err := lr.Length(2); err != nil || n != -1 {
+		t.Fatalf("Length(NULL) = %d, %v; want -1", n, err)
+	}
+
+	// 源值比记录的长度短时报错，而不是死循环
+	if _, _, err := readLOBPiece(lr, 0, 1, 12, 3, 100, true, convertBinary); err == nil {
+		t.Fatal("readLOBPiece succeeded past the end of the value")
+	}
+
+	fail := &failingLOBRow{dmLOBRow: *lr, failAt: 4}
+	if _, _, err := readLOBPiece(fail, 0, 1, 10, 3, 100, true, convertBinary); err == nil || !strings.Contains(err.Error(), "lost connection") {
+		t.Fatalf("readLOBPiece error = %v, want lost connection", err)
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// MemoryBudget 所有表、所有写入协程共享的在途数据内存预算，按估算字节数加权的信号量
+// 读取端攒批前申请，写入端写完一批后释放；等待按申请顺序排队，大行不会被小行饿死
+// 单行超过预算时按整个预算计，独占预算写入
+type MemoryBudget struct {
+	limit int64
+
+	mu      sync.Mutex
+	used    int64
+	peak    int64
+	waiters list.List // *memoryWaiter
+}
+
+type memoryWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewMemoryBudget 创建内存预算，limit <= 0 时返回 nil 表示不限制
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &MemoryBudget{limit: limit}
+}
+
+// weight 单次申请超过预算时按整个预算计
+func (m *MemoryBudget) weight(n int64) int64 {
+	if n > m.limit {
+		return m.limit
+	}
+	return n
+}
+
+// TryAcquire 预算足够且没有人排队时立即占用 n 字节，否则返回 false；m 为空时总是成功
+func (m *MemoryBudget) TryAcquire(n int64) bool {
+	if m == nil {
+		return true
+	}
+	n = m.weight(n)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.waiters.Len() == 0 && m.used+n <= m.limit {
+		m.take(n)
+		return true
+	}
+	return false
+}
+
+// Acquire 占用 n 字节，预算不足时阻塞，ctx 结束时放弃并返回 ctx 的错误
+func (m *MemoryBudget) Acquire(ctx context.Context, n int64) error {
+	if m == nil {
+		return nil
+	}
+	n = m.weight(n)
+	m.mu.Lock()
+	if m.waiters.Len() == 0 && m.used+n <= m.limit {
+		m.take(n)
+		m.mu.Unlock()
+		return nil
+	}
+	w := &memoryWaiter{n: n, ready: make(chan struct{})}
+	elem := m.waiters.PushBack(w)
+	m.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		select {
+		case <-w.ready:
+			// 放弃前刚好轮到，归还后唤醒后面的等待者
+			m.used -= n
+			m.notify()
+		default:
+			m.waiters.Remove(elem)
+			// 排在队首的大申请离开后，后面的小申请可能已经可以满足
+			m.notify()
+		}
+		m.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release 归还 n 字节，n 必须与 Acquire / TryAcquire 时相同
+func (m *MemoryBudget) Release(n int64) {
+	if m == nil || n <= 0 {
+		return
+	}
+	n = m.weight(n)
+	m.mu.Lock()
+	m.used -= n
+	m.notify()
+	m.mu.Unlock()
+}
+
+// take 记录占用，调用方持有锁
+func (m *MemoryBudget) take(n int64) {
+	m.used += n
+	if m.used > m.peak {
+		m.peak = m.used
+	}
+}
+
+// notify 按排队顺序唤醒预算足够的等待者，调用方持有锁
+func (m *MemoryBudget) notify() {
+	for {
+		front := m.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*memoryWaiter)
+		if m.used+w.n > m.limit {
+			return
+		}
+		m.take(w.n)
+		m.waiters.Remove(front)
+		close(w.ready)
+	}
+}
+
+// Usage 返回当前占用、峰值、预算上限和排队等待的申请数
+func (m *MemoryBudget) Usage() (used, peak, limit int64, waiting int) {
+	if m == nil {
+		return 0, 0, 0, 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used, m.peak, m.limit, m.waiters.Len()
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	if NewMemoryBudget(0) != nil {
+		t.Fatal("zero limit should mean no budget")
+	}
+	var none *MemoryBudget
+	if !none.TryAcquire(1 << 40) {
+		t.Fatal("nil budget should always succeed")
+	}
+
+	m := NewMemoryBudget(100)
+	if !m.TryAcquire(60) {
+		t.Fatal("TryAcquire(60) failed")
+	}
+	if m.TryAcquire(50) {
+		t.Fatal("TryAcquire(50) over budget succeeded")
+	}
+
+	// 排队的大申请先于之后的小申请得到预算
+	big := make(chan struct{})
+	go func() {
+		m.Acquire(context.Background(), 80)
+		close(big)
+	}()
+	waitFor(t, func() bool { _, _, _, waiting := m.Usage(); return waiting == 1 })
+	if m.TryAcquire(10) {
+		t.Fatal("TryAcquire jumped the queue")
+	}
+	m.Release(60)
+	<-big
+	if used, peak, limit, waiting := m.Usage(); used != 80 || peak != 80 || limit != 100 || waiting != 0 {
+		t.Fatalf("Usage = %d, %d, %d, %d", used, peak, limit, waiting)
+	}
+
+	// 放弃等待时归还位置，后面的申请可以继续
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Acquire(ctx, 50); err != context.DeadlineExceeded {
+		t.Fatalf("Acquire error = %v", err)
+	}
+	if !m.TryAcquire(20) {
+		t.Fatal("TryAcquire(20) failed after waiter gave up")
+	}
+	m.Release(20)
+	m.Release(80)
+
+	// 超过预算的单次申请按整个预算计
+	if !m.TryAcquire(500) {
+		t.Fatal("oversized TryAcquire on empty budget failed")
+	}
+	if used, _, _, _ := m.Usage(); used != 100 {
+		t.Fatalf("oversized used = %d, want 100", used)
+	}
+	m.Release(500)
+	if used, _, _, _ := m.Usage(); used != 0 {
+		t.Fatalf("used after release = %d", used)
+	}
+}
+
+// waitFor 等待条件成立，最多一秒
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
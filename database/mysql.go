@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,8 +14,11 @@ import (
 
 // MySQLConnector 封装 MySQL 连接操作
 type MySQLConnector struct {
-	db      *sql.DB
-	version int // 例如: 5 代表 MySQL 5.7, 8 代表 MySQL 8.0+
+	db            *sql.DB
+	writeDB       *sql.DB // 批量写入专用的连接池，开启了 clientFoundRows
+	version       int    // 例如: 5 代表 MySQL 5.7, 8 代表 MySQL 8.0+
+	serverVersion string // SELECT VERSION() 返回的实际版本，如 8.0.32
+	maxPacket     int64  // 单条语句允许的最大字节数，用于控制每批数据的体积
 }
 
 // MySQLColumn 定义 MySQL 列元数据结构
@@ -27,11 +31,14 @@ type MySQLColumn struct {
 	Nullable      bool   // true 表示可为空, false 表示必填
 	IsPrimaryKey  bool   // 是否为主键
 	IsAutoIncrement bool // 是否为自增列
+	Offload       bool   // 值写入外部存储，MySQL 中只保存引用
+	SizeOf        string // 不为空时为外存列的大小列，值由转换时生成，不从源表读取
 }
 
 // NewMySQLConnector 初始化 MySQL 连接
-func NewMySQLConnector(dsn string, version int) (*MySQLConnector, error) {
-	db, err := sql.Open("mysql", dsn)
+// sessionInit 为连接池中每条新连接建立后执行的语句，见 SessionSettings
+func NewMySQLConnector(dsn string, version int, sessionInit ...string) (*MySQLConnector, error) {
+	db, err := openMySQL(dsn, sessionInit, false)
 	if err != nil {
 		return nil, err
 	}
@@ -46,34 +53,137 @@ func NewMySQLConnector(dsn string, version int) (*MySQLConnector, error) {
 		return nil, err
 	}
 
-	return &MySQLConnector{db: db, version: version}, nil
+	// 读取实际版本号，用于判断是否支持 8.0.20+ 的行别名语法等特性
+	var serverVersion string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&serverVersion); err != nil {
+		return nil, err
+	}
+
+	maxPacket, err := readMaxPacket(db, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// 批量写入使用单独的连接池并开启 clientFoundRows，upsert 时值未变化的行也计入 affected rows，便于推算更新行数；
+	// 其他语句 (DELETE、UPDATE 等) 的 affected rows 仍按默认规则只计实际变化的行。连接在第一次写入时才建立
+	writeDB, err := openMySQL(dsn, sessionInit, true)
+	if err != nil {
+		return nil, err
+	}
+	writeDB.SetMaxOpenConns(20)
+	writeDB.SetMaxIdleConns(10)
+	writeDB.SetConnMaxLifetime(10 * time.Minute)
+
+	return &MySQLConnector{db: db, writeDB: writeDB, version: version, serverVersion: serverVersion, maxPacket: maxPacket}, nil
+}
+
+// SetMaxOpenConns 调整连接池最大连接数
+func (mc *MySQLConnector) SetMaxOpenConns(n int) {
+	mc.db.SetMaxOpenConns(n)
+	mc.writeDB.SetMaxOpenConns(n)
 }
 
 // Close 关闭数据库连接
 func (mc *MySQLConnector) Close() error {
+	mc.writeDB.Close()
 	return mc.db.Close()
 }
 
-// DisableConstraints 禁用外键和唯一约束检查（用于数据导入前）
-func (mc *MySQLConnector) DisableConstraints() error {
-	// 允许在一个 Exec 中执行多条语句需 DSN开启 multiStatements=true，
-	// 为保险起见，这里分开执行
-	_, err := mc.db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+// TableExists 检查目标库中是否已存在指定的表
+func (mc *MySQLConnector) TableExists(tableName string) (bool, error) {
+	var count int
+	err := mc.db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+		tableName).Scan(&count)
 	if err != nil {
-		return err
+		return false, err
 	}
-	_, err = mc.db.Exec("SET UNIQUE_CHECKS = 0")
-	return err
+	return count > 0, nil
 }
 
-// EnableConstraints 启用外键和唯一约束检查（数据导入后恢复）
-func (mc *MySQLConnector) EnableConstraints() error {
-	_, err := mc.db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+// TruncateTable 清空目标表数据，保留表结构
+func (mc *MySQLConnector) TruncateTable(tableName string) error {
+	_, err := mc.db.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`", tableName))
+	if err != nil {
+		return fmt.Errorf("truncate table error: %v", err)
+	}
+	return nil
+}
+
+// CheckTableCompatible 校验已存在的目标表能否接收源表数据
+// 要求源表的每一列在目标表中都存在且类型能容纳源数据，目标表多出的列可以为空、有默认值或为自增列
+func (mc *MySQLConnector) CheckTableCompatible(tableName string, columns []MySQLColumn) error {
+	rows, err := mc.db.Query(`
+		SELECT COLUMN_NAME, IS_NULLABLE, COLUMN_DEFAULT, EXTRA, DATA_TYPE, COLUMN_TYPE,
+			CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, DATETIME_PRECISION
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, tableName)
 	if err != nil {
 		return err
 	}
-	_, err = mc.db.Exec("SET UNIQUE_CHECKS = 1")
-	return err
+	defer rows.Close()
+
+	// 目标表列，键为小写列名，值为该列是否必须由插入语句提供
+	required := make(map[string]bool)
+	names := make(map[string]string)
+	types := make(map[string]columnType)
+	typeTexts := make(map[string]string)
+	for rows.Next() {
+		var name, nullable, extra, dataType, columnTypeText string
+		var def sql.NullString
+		var charLength, precision, scale, datetimePrecision sql.NullInt64
+		if err := rows.Scan(&name, &nullable, &def, &extra, &dataType, &columnTypeText,
+			&charLength, &precision, &scale, &datetimePrecision); err != nil {
+			return err
+		}
+		key := strings.ToLower(name)
+		names[key] = name
+		required[key] = nullable == "NO" && !def.Valid && !strings.Contains(strings.ToLower(extra), "auto_increment")
+		types[key] = targetColumnType(dataType, columnTypeText, charLength.Int64, int(precision.Int64), int(scale.Int64), int(datetimePrecision.Int64))
+		typeTexts[key] = columnTypeText
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("目标表 %s 不存在或没有任何列", tableName)
+	}
+
+	var missing []string
+	for _, col := range columns {
+		key := strings.ToLower(col.Name)
+		if _, ok := names[key]; !ok {
+			missing = append(missing, col.Name)
+		}
+		delete(required, key)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("目标表 %s 缺少源表列: %s", tableName, strings.Join(missing, ", "))
+	}
+
+	var mismatched []string
+	for _, col := range columns {
+		key := strings.ToLower(col.Name)
+		want, text := expectedColumnType(col, mc.version)
+		if !want.holds(types[key]) {
+			mismatched = append(mismatched, describeMismatch(col, text, typeTexts[key]))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("目标表 %s 的列类型无法容纳源表数据: %s", tableName, strings.Join(mismatched, "; "))
+	}
+
+	var unfilled []string
+	for key, req := range required {
+		if req {
+			unfilled = append(unfilled, names[key])
+		}
+	}
+	if len(unfilled) > 0 {
+		sort.Strings(unfilled)
+		return fmt.Errorf("目标表 %s 存在源表没有的非空且无默认值的列: %s", tableName, strings.Join(unfilled, ", "))
+	}
+	return nil
 }
 
 // CreateTable 根据通用的 Column 定义创建 MySQL 表
@@ -146,6 +256,14 @@ func (mc *MySQLConnector) CreateTable(tableName string, columns []MySQLColumn) e
 
 // convertDMTypeToMySQL 将达梦/Oracle 类型映射为最佳的 MySQL 类型
 func convertDMTypeToMySQL(col MySQLColumn, version int) string {
+	// 外存列只保存相对路径，大小列保存原始字节数
+	if col.Offload {
+		return "VARCHAR(255)"
+	}
+	if col.SizeOf != "" {
+		return "BIGINT"
+	}
+
 	// 转大写并去除首尾空格，防止 " INT " 这种奇怪情况
 	originType := strings.ToUpper(strings.TrimSpace(col.DataType))
 
@@ -255,46 +373,174 @@ func convertDMTypeToMySQL(col MySQLColumn, version int) string {
 	return "LONGTEXT"
 }
 
-// execWithRetry 带重试机制的执行函数
-func (mc *MySQLConnector) execWithRetry(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	var result sql.Result
-	var err error
-	
-	// 最多重试3次
-	for i := 0; i < 3; i++ {
-		result, err = mc.db.ExecContext(ctx, query, args...)
-		if err == nil {
-			return result, nil
-		}
-		
-		// 如果是连接错误，尝试重新连接
-		if strings.Contains(err.Error(), "connection refused") || 
-		   strings.Contains(err.Error(), "broken pipe") || 
-		   strings.Contains(err.Error(), "invalid connection") ||
-		   strings.Contains(err.Error(), "connection lost") {
-			log.Printf("⚠️  检测到连接问题，尝试重新连接 (%d/3)", i+1)
-			time.Sleep(time.Duration(i+1) * time.Second) // 逐渐增加等待时间
-			continue
+// pinnedConn 固定使用连接池中的一条连接，连接出错后归还并在下次执行时换一条新连接
+type pinnedConn struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// BeginTx 在固定的连接上开启事务
+func (pc *pinnedConn) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	if pc.conn == nil {
+		conn, err := pc.db.Conn(ctx)
+		if err != nil {
+			return nil, err
 		}
-		
-		// 非连接错误直接返回
-		break
+		pc.conn = conn
 	}
-	
-	return result, err
+	tx, err := pc.conn.BeginTx(ctx, nil)
+	if err != nil && ClassifyError(err) == ErrorClassConnection {
+		pc.Close()
+	}
+	return tx, err
+}
+
+// Close 将连接归还连接池
+func (pc *pinnedConn) Close() error {
+	if pc.conn == nil {
+		return nil
+	}
+	err := pc.conn.Close()
+	pc.conn = nil
+	return err
+}
+
+// InsertOptions 批量写入参数
+type InsertOptions struct {
+	BatchSize  int         // 用户期望的每批次行数 (会自动调整以适应 MySQL 占位符限制)
+	WriteMode  WriteMode   // 写入方式，为空时等同于 WriteModeInsert
+	DeadLetter *DeadLetter // 不为空时开启行级错误隔离，失败批次二分定位坏行并写入死信文件
+	Writers    int         // 写入协程数，每个协程使用独立的 MySQL 连接，默认 1
+	Loader     Loader      // 导入方式，为空时等同于 LoaderInsert
+
+	AdaptiveBatch bool          // 根据每批写入耗时自动调整批大小，BatchSize 作为初始值
+	TargetLatency time.Duration // 自适应模式下每批的目标写入耗时
+
+	LOB     *LOBStream // 不为空时大字段分块流式导入，源数据只包含 LOB.ReadColumns 返回的列
+	Offload *Offloader // 外存列的写入器，表中有外存列时必须设置
+
+	Memory *MemoryBudget // 所有表共享的在途数据内存预算，为空时不限制
+
+	ReadLimit  Throttle   // 读取源数据的限速，按行数和估算字节数计
+	WriteLimit Throttle   // 写入 MySQL 的限速，按行数和估算字节数计
+	Guard      *LoadGuard // 目标库负载保护，每批写入前检查，可以为空
+	Schedule   *Schedule  // 允许读取源库的时间窗口，窗口外读取端在批次之间暂停，可以为空
+
+	Retry *RetryPolicy // 写入失败时的重试策略，为空时使用 DefaultRetryPolicy
+
+	KeyColumn string                    // 源数据按该列升序读取，配合 OnCommit 记录检查点
+	OnCommit  func(lastKey, rows int64) // 已连续提交的批次推进时回调最大键值和新增行数，可能在写入协程中调用
+
+	Context context.Context // 取消后读取端停止读取，写入端写完手头的批次后退出，导入返回取消原因；为空时不会被取消
+}
+
+// ctx 返回导入的上下文
+func (o InsertOptions) ctx() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// abortedError 导入被取消时返回的错误
+func abortedError(ctx context.Context) error {
+	return fmt.Errorf("导入被中止: %w", context.Cause(ctx))
+}
+
+// retryPolicy 返回写入失败时的重试策略
+func (o InsertOptions) retryPolicy() RetryPolicy {
+	if o.Retry == nil {
+		return DefaultRetryPolicy()
+	}
+	return *o.Retry
+}
+
+// InsertStats 批量写入统计
+// 插入/更新/忽略行数由每批语句返回的 affected rows 推算
+type InsertStats struct {
+	Rows     int64 // 从源表读取的行数
+	Inserted int64 // 新插入的行数
+	Updated  int64 // upsert 模式下被更新、replace 模式下被替换的行数
+	Ignored  int64 // ignore 模式下因键冲突被忽略的行数
+	Failed   int64 // 写入死信文件的坏行数
+	Retries  int64 // 因可重试错误重新执行的事务数
+	Resumes  int64 // 读取源表中途出错后重新查询的次数
+
+	InsertedOrSame int64 // upsert 模式下新插入或已存在且值未变化的行数，两者的 affected rows 相同，无法区分
+
+	ReadWait     time.Duration // 写入协程空闲等待读取端供数的时间 (各协程平均)，偏大说明达梦读取是瓶颈
+	WriteWait    time.Duration // 读取端等待写入协程接收批次的时间，偏大说明 MySQL 写入是瓶颈
+	MemWait      time.Duration // 读取端等待内存预算的时间
+	RateWait     time.Duration // 读取端和写入协程因限速等待的时间之和
+	GuardWait    time.Duration // 写入协程因目标库负载过高暂停或减速的时间之和
+	ScheduleWait time.Duration // 读取端在时间窗口外暂停的时间
+
+	BatchMin int // 运行过程中使用过的最小批大小
+	BatchMax int // 运行过程中使用过的最大批大小
+}
+
+// Add 累加另一份统计，用于汇总各分片的结果
+func (s *InsertStats) Add(o InsertStats) {
+	s.Rows += o.Rows
+	s.Inserted += o.Inserted
+	s.Updated += o.Updated
+	s.InsertedOrSame += o.InsertedOrSame
+	s.Ignored += o.Ignored
+	s.Failed += o.Failed
+	s.Retries += o.Retries
+	s.Resumes += o.Resumes
+	s.ReadWait += o.ReadWait
+	s.WriteWait += o.WriteWait
+	s.MemWait += o.MemWait
+	s.RateWait += o.RateWait
+	s.GuardWait += o.GuardWait
+	s.ScheduleWait += o.ScheduleWait
+	if o.BatchMin > 0 && (s.BatchMin == 0 || o.BatchMin < s.BatchMin) {
+		s.BatchMin = o.BatchMin
+	}
+	if o.BatchMax > s.BatchMax {
+		s.BatchMax = o.BatchMax
+	}
+}
+
+// Breakdown 描述插入/更新/忽略的行数，upsert 模式下无法区分的新插入和值未变化的行合并列出
+func (s InsertStats) Breakdown() string {
+	parts := []string{fmt.Sprintf("插入 %d", s.Inserted)}
+	if s.InsertedOrSame > 0 {
+		parts = append(parts, fmt.Sprintf("插入或未变化 %d", s.InsertedOrSame))
+	}
+	parts = append(parts, fmt.Sprintf("更新 %d", s.Updated), fmt.Sprintf("忽略 %d", s.Ignored))
+	return strings.Join(parts, ", ")
+}
+
+// Unwritten 返回没有成为目标表新行的源表行数: 写入死信的坏行，以及因重复键被忽略、或更新/替换了已写入行的行
+// 目标表导入前为空时，目标表行数应为读取的行数减去该值
+func (s InsertStats) Unwritten() int64 {
+	return s.Failed + s.Ignored + s.Updated
+}
+
+// Bottleneck 根据等待时间判断瓶颈所在
+func (s InsertStats) Bottleneck() string {
+	if s.ReadWait > s.WriteWait {
+		return "达梦读取"
+	}
+	return "MySQL写入"
 }
 
 // BatchInsertData 执行分批插入
+// 读取端在当前协程中扫描源数据并攒批，通过有界通道交给 opts.Writers 个写入协程并发写入
 // rows: 源数据库查询结果集
-// userBatchSize: 用户期望的每批次行数 (会自动调整以适应 MySQL 占位符限制)
-func (mc *MySQLConnector) BatchInsertData(tableName string, columns []MySQLColumn, rows *sql.Rows, userBatchSize int) (int64, error) {
+// opts.BatchSize: 用户期望的每批次行数 (会自动调整以适应 MySQL 占位符限制)
+func (mc *MySQLConnector) BatchInsertData(tableName string, columns []MySQLColumn, rows RowSource, opts InsertOptions) (InsertStats, error) {
+	var stats InsertStats
 	colCount := len(columns)
 	if colCount == 0 {
-		return 0, nil
+		return stats, nil
 	}
 
 	// 计算安全的 batchSize
 	// MySQL 预处理语句参数限制通常为 65535，保险起见设为 60000
+	userBatchSize := opts.BatchSize
 	maxPlaceholders := 60000
 	safeBatchSize := maxPlaceholders / colCount
 
@@ -305,97 +551,203 @@ func (mc *MySQLConnector) BatchInsertData(tableName string, columns []MySQLColum
 	if userBatchSize < 1 {
 		userBatchSize = 1
 	}
+	if opts.Writers < 1 {
+		opts.Writers = 1
+	}
+	if opts.WriteMode == "" {
+		opts.WriteMode = WriteModeInsert
+	}
 
-	log.Printf("📝 表 %s 批处理大小设置为 %d (每批 %d 行, %d 列)", tableName, userBatchSize, userBatchSize, colCount)
+	if opts.LOB != nil {
+		if opts.Loader == LoaderLoadData {
+			log.Printf("⚠️  表 %s 分块导入大字段，不使用 LOAD DATA", tableName)
+		}
+		return mc.insertWithLOBs(tableName, columns, rows, opts)
+	}
 
-	// 准备 SQL 模板
-	// 结果如: INSERT INTO `table` (`col1`, `col2`) VALUES
-	colNames := make([]string, colCount)
-	placeholders := make([]string, colCount)
-	for i, col := range columns {
-		colNames[i] = "`" + col.Name + "`"
-		placeholders[i] = "?"
+	if opts.Loader == LoaderLoadData {
+		if ok, reason := mc.canLoadData(opts); !ok {
+			log.Printf("⚠️  表 %s 无法使用 LOAD DATA (%s)，改用 INSERT 导入", tableName, reason)
+		} else {
+			loaded, fallback, err := mc.loadData(tableName, columns, rows, opts)
+			if !fallback {
+				return loaded, err
+			}
+			log.Printf("⚠️  服务端拒绝 LOAD DATA LOCAL，表 %s 改用 INSERT 导入", tableName)
+		}
+	}
+
+	// 自适应模式下批大小可以一直放大到占位符上限
+	sizer := newBatchSizer(tableName, userBatchSize, max(safeBatchSize, userBatchSize), opts.AdaptiveBatch, opts.TargetLatency)
+	pool := mc.startWriters(tableName, columns, opts, sizer)
+
+	// 按键有序读取时，每批最后一行的键值就是该批的最大键值
+	keyIdx := -1
+	if opts.KeyColumn != "" && opts.OnCommit != nil {
+		for i, col := range columns {
+			if col.Name == opts.KeyColumn {
+				keyIdx = i
+				break
+			}
+		}
+		if keyIdx >= 0 {
+			pool.tracker = newCommitTracker(opts.OnCommit)
+		}
+	}
+	var mark batchMark
+
+	// 含大字段的表按行数攒批容易超过 max_allowed_packet，同时按估算字节数限制每批体积
+	byteBudget := mc.batchByteBudget(pool.templateLen)
+	log.Printf("📝 表 %s 批处理大小设置为 %d (每批最多 %d 行或约 %d 字节, %d 列, 写入方式 %s, 写入协程 %d)",
+		tableName, userBatchSize, userBatchSize, byteBudget, colCount, opts.WriteMode, opts.Writers)
+	if sizer.adaptive {
+		log.Printf("🎚️  表 %s 开启自适应批大小 (初始 %d 行, 范围 %d~%d 行, 目标耗时 %v)",
+			tableName, sizer.size, sizer.min, sizer.max, opts.TargetLatency)
 	}
-	baseSQL := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES ", tableName, strings.Join(colNames, ", "))
-	rowPlaceholder := fmt.Sprintf("(%s)", strings.Join(placeholders, ", "))
 
 	// 变量初始化
-	var totalRows int64 = 0
-	var batchValues []interface{}
-	var batchPlaceholders []string
+	var batch [][]interface{}
+	var batchBytes int64         // 当前批次的估算字节数，也是已占用的内存预算
+	var offloaded []OffloadEntry // 当前批次外存列的清单记录，提交后写入清单
+	var readErr error
+
+	// 交给写入端后批次归写入端所有，提交失败时 submit 已归还预算
+	flush := func() bool {
+		ok := pool.submit(batch, mark, batchBytes, offloaded)
+		batch, batchBytes, mark, offloaded = nil, 0, batchMark{}, nil
+		return ok
+	}
 
-	// 用于 Scan 的容器
-	scanArgs := make([]interface{}, colCount)
-	values := make([]interface{}, colCount)
+	// 用于 Scan 的容器，每列按类型转换后再写入
+	rc := newRowConverter(columns, mc.version, opts.Offload)
+	scanArgs := make([]interface{}, rc.width())
+	values := make([]interface{}, rc.width())
 	for i := range values {
 		scanArgs[i] = &values[i]
 	}
 
 	// 遍历数据
 	lastReportTime := time.Now()
-	for rows.Next() {
-		err := rows.Scan(scanArgs...)
+	for {
+		if pool.ctx.Err() != nil {
+			// 写入端已失败或导入被取消
+			break
+		}
+		// 时间窗口外在批次之间暂停，已提交的批次由写入端写完
+		if len(batch) == 0 {
+			suspendIfClosed(opts.Schedule, rows)
+			waited, err := opts.Schedule.Wait(pool.ctx)
+			stats.ScheduleWait += waited
+			if err != nil {
+				// 写入端已失败
+				break
+			}
+		}
+		if !rows.Next() {
+			break
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			readErr = fmt.Errorf("scan rows error: %v", err)
+			break
+		}
+
+		// Scan 的容器会被复用，转换结果每行单独一份
+		row, err := rc.convert(values)
 		if err != nil {
-			return totalRows, fmt.Errorf("scan rows error: %v", err)
+			readErr = fmt.Errorf("表 %s 第 %d 行: %v", tableName, stats.Rows+1, err)
+			break
 		}
+		rowOffloaded := rc.takeOffloaded()
+
+		stats.Rows++
 
-		// 处理读取到的数据
-		for _, v := range values {
-			if b, ok := v.([]byte); ok {
-				// 将 []byte 转为 string，防止某些情况下的乱码或 hex 显示
-				batchValues = append(batchValues, string(b))
-			} else {
-				batchValues = append(batchValues, v)
+		// 单行就超过预算时先送出已攒的批次，这一行单独成批
+		rowBytes := estimateRowBytes(row)
+		waited, err := opts.ReadLimit.Wait(pool.ctx, 1, rowBytes)
+		stats.RateWait += waited
+		if err != nil {
+			// 写入端已失败
+			break
+		}
+		if rowBytes > byteBudget {
+			if rowBytes > mc.maxPacket {
+				readErr = mc.oversizedRowError(tableName, rowBytes)
+				break
+			}
+			log.Printf("⚠️  表 %s 第 %d 行约 %d 字节，单独成批写入", tableName, stats.Rows, rowBytes)
+		}
+		if len(batch) > 0 && batchBytes+rowBytes > byteBudget {
+			if !flush() {
+				break
 			}
 		}
 
-		batchPlaceholders = append(batchPlaceholders, rowPlaceholder)
-		totalRows++
-
-		// 缓冲区满，执行插入
-		if len(batchPlaceholders) >= userBatchSize {
-			stmt := baseSQL + strings.Join(batchPlaceholders, ",")
-			log.Printf("📤 正在插入表 %s 的一批数据 (%d 行)", tableName, len(batchPlaceholders))
-			
-			// 创建带超时的上下文
-			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-			_, err := mc.execWithRetry(ctx, stmt, batchValues...)
-			cancel()
-			
+		// 内存预算不足时先送出已攒的部分批次再等待，避免自己占着预算等自己
+		if !opts.Memory.TryAcquire(rowBytes) {
+			if len(batch) > 0 && !flush() {
+				break
+			}
+			start := time.Now()
+			err := opts.Memory.Acquire(pool.ctx, rowBytes)
+			stats.MemWait += time.Since(start)
 			if err != nil {
-				return totalRows, fmt.Errorf("batch exec error: %v", err)
+				// 写入端已失败
+				break
+			}
+		}
+
+		if keyIdx >= 0 {
+			key, ok := keyToInt64(row[keyIdx])
+			if !ok {
+				opts.Memory.Release(rowBytes)
+				readErr = fmt.Errorf("无法解析键列 %s 的值 %v", opts.KeyColumn, row[keyIdx])
+				break
 			}
-			
-			log.Printf("📥 表 %s 的一批数据插入完成 (%d 行)", tableName, len(batchPlaceholders))
-			
-			// 清空缓冲区
-			batchValues = nil
-			batchPlaceholders = nil
-			
+			mark.lastKey = key
+		}
+		mark.rows++
+		batch = append(batch, row)
+		batchBytes += rowBytes
+		offloaded = append(offloaded, rowOffloaded...)
+
+		// 缓冲区满，交给写入端
+		if len(batch) >= sizer.current() || batchBytes >= byteBudget {
+			if !flush() {
+				// 写入端已失败，停止读取
+				break
+			}
+
 			// 每隔一段时间报告一次进度
 			if time.Since(lastReportTime) > 30*time.Second {
-				log.Printf("📊 表 %s 已处理 %d 行", tableName, totalRows)
+				log.Printf("📊 表 %s 已处理 %d 行", tableName, stats.Rows)
 				lastReportTime = time.Now()
 			}
 		}
 	}
-
-	// 处理剩余数据
-	if len(batchPlaceholders) > 0 {
-		stmt := baseSQL + strings.Join(batchPlaceholders, ",")
-		log.Printf("📤 正在插入表 %s 的最后一批数据 (%d 行)", tableName, len(batchPlaceholders))
-		
-		// 创建带超时的上下文
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		_, err := mc.execWithRetry(ctx, stmt, batchValues...)
-		cancel()
-		
-		if err != nil {
-			return totalRows, fmt.Errorf("final batch exec error: %v", err)
+	if readErr == nil {
+		if err := rows.Err(); err != nil {
+			readErr = fmt.Errorf("read rows error: %v", err)
 		}
-		
-		log.Printf("📥 表 %s 的最后一批数据插入完成 (%d 行)", tableName, len(batchPlaceholders))
+	}
+	if readErr == nil && opts.ctx().Err() != nil {
+		readErr = abortedError(opts.ctx())
+	}
+
+	// 处理剩余数据，读取失败时未提交的批次不再写入
+	if readErr == nil && len(batch) > 0 {
+		flush()
+	} else {
+		opts.Memory.Release(batchBytes)
 	}
 
-	return totalRows, nil
+	written, err := pool.close()
+	stats.Add(written)
+	sizer.record(&stats)
+	if readErr != nil {
+		return stats, readErr
+	}
+	if err != nil {
+		return stats, fmt.Errorf("batch exec error: %v", err)
+	}
+	return stats, nil
 }
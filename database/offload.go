@@ -0,0 +1,261 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OffloadSizeSuffix 外存列对应的大小列的后缀，如 SCAN_IMAGE 的大小保存在 SCAN_IMAGE_size
+const OffloadSizeSuffix = "_size"
+
+// Offloader 把大字段写入按 SHA-256 寻址的外部目录，MySQL 中只保存相对路径
+// 目录结构: <dir>/objects/ab/cd/<sha256>[.gz]，<dir>/manifest/<表名>.jsonl
+// 内容相同的值只保存一份；清单只记录已提交到 MySQL 的行，见 Record
+type Offloader struct {
+	dir   string
+	gzip  bool
+	table string
+
+	mu       sync.Mutex
+	manifest *os.File
+	w        *bufio.Writer
+}
+
+// OffloadEntry 清单中的一条记录，可用于校验外部文件或把内容重新导回数据库
+type OffloadEntry struct {
+	Table  string                 `json:"table"`
+	Column string                 `json:"column"`
+	Key    map[string]interface{} `json:"key,omitempty"` // 行的主键
+	SHA256 string                 `json:"sha256"`        // 原始内容的摘要
+	Size   int64                  `json:"size"`          // 原始字节数
+	Path   string                 `json:"path"`          // 相对外存目录的路径，即 MySQL 中保存的引用
+	Gzip   bool                   `json:"gzip"`
+}
+
+// NewOffloader 创建表的外存写入器，appendManifest 为 false 时清空该表原有的清单
+func NewOffloader(dir, table string, gz, appendManifest bool) (*Offloader, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "manifest"), 0755); err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !appendManifest {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "manifest", table+".jsonl"), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Offloader{dir: dir, gzip: gz, table: table, manifest: f, w: bufio.NewWriter(f)}, nil
+}
+
+// ObjectPath 返回内容摘要对应的相对路径
+func ObjectPath(sum string, gz bool) string {
+	p := filepath.Join("objects", sum[:2], sum[2:4], sum)
+	if gz {
+		p += ".gz"
+	}
+	return filepath.ToSlash(p)
+}
+
+// Put 保存一个值，返回对应的清单记录，其 Path 即写入 MySQL 的引用
+// 此时尚未记入清单，该行提交后再调用 Record，避免清单中出现未写入 MySQL 的行
+func (o *Offloader) Put(column string, key map[string]interface{}, data []byte) (OffloadEntry, error) {
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	rel := ObjectPath(sum, o.gzip)
+
+	if err := o.writeObject(filepath.Join(o.dir, filepath.FromSlash(rel)), data); err != nil {
+		return OffloadEntry{}, fmt.Errorf("写入外存文件失败: %v", err)
+	}
+	return OffloadEntry{
+		Table:  o.table,
+		Column: column,
+		Key:    key,
+		SHA256: sum,
+		Size:   int64(len(data)),
+		Path:   rel,
+		Gzip:   o.gzip,
+	}, nil
+}
+
+// Record 把已提交的行的清单记录追加到清单并立即刷新，中途退出时清单与 MySQL 中已提交的行一致
+// o 为空或 entries 为空时不做任何事
+func (o *Offloader) Record(entries []OffloadEntry) error {
+	if o == nil || len(entries) == 0 {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		o.w.Write(line)
+		if err := o.w.WriteByte('\n'); err != nil {
+			return fmt.Errorf("写入外存清单失败: %v", err)
+		}
+	}
+	if err := o.w.Flush(); err != nil {
+		return fmt.Errorf("写入外存清单失败: %v", err)
+	}
+	return nil
+}
+
+// writeObject 写入内容文件，已存在时跳过；先写临时文件再重命名，中途退出不会留下残缺文件
+func (o *Offloader) writeObject(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var w io.Writer = tmp
+	var zw *gzip.Writer
+	if o.gzip {
+		zw = gzip.NewWriter(tmp)
+		w = zw
+	}
+	if _, err := w.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Close 刷新并关闭清单，o 为空时不做任何事
+func (o *Offloader) Close() error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.w.Flush(); err != nil {
+		o.manifest.Close()
+		return err
+	}
+	return o.manifest.Close()
+}
+
+// ReadOffloadObject 读取外存文件的原始内容
+func ReadOffloadObject(dir string, e OffloadEntry) ([]byte, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if e.Gzip {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyOffloadEntry 校验外存文件的大小和摘要是否与清单一致
+func VerifyOffloadEntry(dir string, e OffloadEntry) error {
+	data, err := ReadOffloadObject(dir, e)
+	if err != nil {
+		return err
+	}
+	return CheckOffloadData(e, data)
+}
+
+// CheckOffloadData 校验读出的内容与清单记录的大小和摘要是否一致
+func CheckOffloadData(e OffloadEntry, data []byte) error {
+	if int64(len(data)) != e.Size {
+		return fmt.Errorf("大小不一致: 清单 %d 字节，文件 %d 字节", e.Size, len(data))
+	}
+	h := sha256.Sum256(data)
+	if sum := hex.EncodeToString(h[:]); sum != e.SHA256 {
+		return fmt.Errorf("摘要不一致: 清单 %s，文件 %s", e.SHA256, sum)
+	}
+	return nil
+}
+
+// ReadOffloadManifest 逐条读取清单文件
+func ReadOffloadManifest(path string, fn func(OffloadEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		// 主键按 json.Number 解析，大整数不会丢失精度
+		var e OffloadEntry
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&e); err != nil {
+			return fmt.Errorf("%s 第 %d 行: %v", path, line, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// RestoreOffloaded 把外存内容写回 MySQL 表的 into 列，按清单中的主键定位行，返回受影响的行数
+func (mc *MySQLConnector) RestoreOffloaded(tableName, into string, key map[string]interface{}, data []byte) (int64, error) {
+	if len(key) == 0 {
+		return 0, fmt.Errorf("清单记录缺少主键")
+	}
+	cols := make([]string, 0, len(key))
+	for k := range key {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	conds := make([]string, len(cols))
+	args := []interface{}{data}
+	for i, k := range cols {
+		conds[i] = "`" + k + "` = ?"
+		args = append(args, key[k])
+	}
+	query := fmt.Sprintf("UPDATE `%s` SET `%s` = ? WHERE %s", tableName, into, strings.Join(conds, " AND "))
+	result, err := mc.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
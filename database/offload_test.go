@@ -0,0 +1,44 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOffloaderRecordAfterCommit(t *testing.T) {
+	dir := t.TempDir()
+	o, err := NewOffloader(dir, "DOCS", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	manifest := filepath.Join(dir, "manifest", "DOCS.jsonl")
+
+	data := []byte("scanned image")
+	entry, err := o.Put("BODY", map[string]interface{}{"ID": int64(1)}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyOffloadEntry(dir, entry); err != nil {
+		t.Fatalf("object not written: %v", err)
+	}
+	if fi, err := os.Stat(manifest); err != nil || fi.Size() != 0 {
+		t.Fatalf("manifest written before commit: %v, %v", fi, err)
+	}
+
+	if err := o.Record([]OffloadEntry{entry}); err != nil {
+		t.Fatal(err)
+	}
+	// 不关闭写入器，记录也应已刷新到文件
+	var got []OffloadEntry
+	if err := ReadOffloadManifest(manifest, func(e OffloadEntry) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != entry.Path || got[0].SHA256 != entry.SHA256 || got[0].Size != int64(len(data)) {
+		t.Fatalf("manifest = %+v, want %+v", got, entry)
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// packetHeadroom 字节预算只使用 max_allowed_packet 的一部分，给估算误差和转义留出余量
+const packetHeadroom = 0.8
+
+// readMaxPacket 读取单条语句允许的最大字节数
+// 取服务端 max_allowed_packet 与 DSN 中 maxAllowedPacket 的较小值 (DSN 配置为 0 时驱动跟随服务端)
+func readMaxPacket(db *sql.DB, dsn string) (int64, error) {
+	var serverMax int64
+	if err := db.QueryRow("SELECT @@max_allowed_packet").Scan(&serverMax); err != nil {
+		return 0, err
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.MaxAllowedPacket > 0 && int64(cfg.MaxAllowedPacket) < serverMax {
+		return int64(cfg.MaxAllowedPacket), nil
+	}
+	return serverMax, nil
+}
+
+// batchByteBudget 一批数据的字节预算，已扣除语句模板本身的长度
+func (mc *MySQLConnector) batchByteBudget(templateLen int) int64 {
+	budget := int64(float64(mc.maxPacket)*packetHeadroom) - int64(templateLen)
+	if budget < 1 {
+		budget = 1
+	}
+	return budget
+}
+
+// estimateRowBytes 估算一行数据插值到 SQL 语句后的字节数
+// 字符串和二进制按长度加上约 1/16 的转义开销计算，其余类型按常见的字面量长度计算
+func estimateRowBytes(row []interface{}) int64 {
+	n := int64(3) // 括号和分隔逗号
+	for _, v := range row {
+		switch x := v.(type) {
+		case nil:
+			n += 5 // NULL,
+		case string:
+			n += int64(len(x)+len(x)/16) + 3
+		case []byte:
+			n += int64(len(x)+len(x)/16) + 11 // _binary'...'
+		case time.Time:
+			n += 29 // '2006-01-02 15:04:05.999999',
+		default:
+			n += 21
+		}
+	}
+	return n
+}
+
+// isPacketTooLarge 判断错误是否由语句超过 max_allowed_packet 引起
+func isPacketTooLarge(err error) bool {
+	if errors.Is(err, mysql.ErrPktTooLarge) {
+		return true
+	}
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1153 // ER_NET_PACKET_TOO_LARGE
+}
+
+// oversizedRowError 单行数据超过 max_allowed_packet 时返回的错误
+func (mc *MySQLConnector) oversizedRowError(tableName string, rowBytes int64) error {
+	return fmt.Errorf("表 %s 存在单行约 %d 字节的数据，超过 max_allowed_packet (%d 字节)，"+
+		"请调大服务端 max_allowed_packet (及 DSN 中的 maxAllowedPacket) 后重试", tableName, rowBytes, mc.maxPacket)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket 令牌桶，容量为一秒的速率，速率可在运行中调整
+// 单次申请超过桶容量时允许透支，由之后的申请等待补足，长期速率不变
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数，<= 0 表示不限制
+	tokens float64
+	last   time.Time
+}
+
+// setRate 调整速率，桶中已有的令牌不超过新的容量
+func (b *tokenBucket) setRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.rate <= 0 {
+		// 从不限制切换为限制时桶是满的
+		b.tokens = rate
+	}
+	b.rate = rate
+	if b.tokens > rate {
+		b.tokens = rate
+	}
+}
+
+// refill 按经过的时间补充令牌，调用方持有锁
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+	}
+	b.last = now
+}
+
+// take 取走 n 个令牌，返回需要等待的时间
+func (b *tokenBucket) take(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 || n <= 0 {
+		return 0
+	}
+	b.refill(time.Now())
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// RateLimit 每秒行数和每秒字节数的限速，两个令牌桶都要满足
+type RateLimit struct {
+	rows  tokenBucket
+	bytes tokenBucket
+}
+
+// NewRateLimit 创建限速，参数 <= 0 表示该项不限制
+func NewRateLimit(rowsPerSec, bytesPerSec float64) *RateLimit {
+	l := &RateLimit{}
+	l.Set(rowsPerSec, bytesPerSec)
+	return l
+}
+
+// Set 调整速率，正在等待的调用不受影响，之后的调用按新速率计算
+func (l *RateLimit) Set(rowsPerSec, bytesPerSec float64) {
+	l.rows.setRate(rowsPerSec)
+	l.bytes.setRate(bytesPerSec)
+}
+
+// Rates 返回当前的每秒行数和每秒字节数
+func (l *RateLimit) Rates() (rowsPerSec, bytesPerSec float64) {
+	l.rows.mu.Lock()
+	rowsPerSec = l.rows.rate
+	l.rows.mu.Unlock()
+	l.bytes.mu.Lock()
+	bytesPerSec = l.bytes.rate
+	l.bytes.mu.Unlock()
+	return rowsPerSec, bytesPerSec
+}
+
+// reserve 申请 rows 行、bytes 字节，返回需要等待的时间
+func (l *RateLimit) reserve(rows, bytes int64) time.Duration {
+	return max(l.rows.take(float64(rows)), l.bytes.take(float64(bytes)))
+}
+
+// Throttle 同时生效的一组限速，如全局限速加表级限速，为空时不限制
+type Throttle []*RateLimit
+
+// Wait 申请 rows 行、bytes 字节，令牌不足时等待，ctx 结束时提前返回 ctx 的错误
+// 返回实际等待的时间
+func (t Throttle) Wait(ctx context.Context, rows, bytes int64) (time.Duration, error) {
+	var wait time.Duration
+	for _, l := range t {
+		if l != nil {
+			wait = max(wait, l.reserve(rows, bytes))
+		}
+	}
+	if wait <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return wait, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	if d := b.take(1e9); d != 0 {
+		t.Fatalf("unlimited bucket waited %v", d)
+	}
+
+	b.setRate(100)
+	// 从不限制切换为限制时桶是满的
+	if d := b.take(100); d != 0 {
+		t.Fatalf("full bucket waited %v", d)
+	}
+	// 透支 50 个令牌，按每秒 100 个需要约 0.5 秒补足
+	if d := b.take(50); d < 450*time.Millisecond || d > 500*time.Millisecond {
+		t.Fatalf("overdraft wait = %v, want about 500ms", d)
+	}
+
+	// 降低速率时桶中的令牌不超过新容量
+	var c tokenBucket
+	c.setRate(1000)
+	c.setRate(10)
+	if d := c.take(20); d < 900*time.Millisecond || d > time.Second {
+		t.Fatalf("after lowering rate wait = %v, want about 1s", d)
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	var none Throttle
+	if d, err := none.Wait(context.Background(), 1e6, 1e9); d != 0 || err != nil {
+		t.Fatalf("empty throttle = %v, %v", d, err)
+	}
+
+	// 两个限速同时生效，取较慢的一个
+	global := NewRateLimit(0, 1000)
+	table := NewRateLimit(10, 0)
+	th := Throttle{global, nil, table}
+	if d, err := th.Wait(context.Background(), 10, 1000); d != 0 || err != nil {
+		t.Fatalf("first wait = %v, %v", d, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := th.Wait(ctx, 10, 10); err != context.Canceled {
+		t.Fatalf("canceled wait error = %v", err)
+	}
+
+	rows, bytes := table.Rates()
+	if rows != 10 || bytes != 0 {
+		t.Fatalf("Rates = %v, %v", rows, bytes)
+	}
+}
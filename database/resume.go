@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResumeKey 返回可用于断点续传的键列: 单列整数主键，没有时返回空串
+// 续传需要在 MySQL 端按键删除未确认的行，ROWID 在目标库中不存在，因此不能用于续传
+func ResumeKey(cols []DMColumn) string {
+	if key := chunkKey(cols); key != rowIDKey {
+		return key
+	}
+	return ""
+}
+
+// GetTableDataAfter 按键升序读取键值大于 after 的数据，after 为空时从头读取
+// r 不为空时只读取该分片区间内的行
+func (dmc *DMConnector) GetTableDataAfter(tableName string, cols []string, key string, after *int64, r *ChunkRange) (*sql.Rows, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	keyExpr := quoteDMIdent(key)
+
+	var conds []string
+	var args []interface{}
+	if r != nil {
+		conds = append(conds, keyExpr+" BETWEEN ? AND ?")
+		args = append(args, r.Lo, r.Hi)
+	}
+	if after != nil {
+		conds = append(conds, keyExpr+" > ?")
+		args = append(args, *after)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", selectList(cols), dmc.readFrom(realTableName))
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY " + keyExpr
+	return dmc.db.Query(query, args...)
+}
+
+// DeleteKeyRange 删除目标表中键值在 [lo, hi] 闭区间内的行，返回删除的行数
+// 续传前用于清理检查点之后可能已经写入、但尚未记录到检查点的行
+func (mc *MySQLConnector) DeleteKeyRange(tableName, key string, lo, hi int64) (int64, error) {
+	result, err := mc.db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE `%s` BETWEEN ? AND ?", tableName, key), lo, hi)
+	if err != nil {
+		return 0, fmt.Errorf("delete rows error: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// commitTracker 跟踪按键有序读取时已连续提交的前缀
+// 多个写入协程提交批次的顺序不确定，只有某一批之前的所有批次都已提交，它的最大键值才能作为检查点
+type commitTracker struct {
+	mu       sync.Mutex
+	next     int64 // 下一个等待提交的批次序号
+	pending  map[int64]batchMark
+	onCommit func(lastKey, rows int64)
+}
+
+// batchMark 一个批次的最大键值和行数
+type batchMark struct {
+	lastKey int64
+	rows    int64
+}
+
+func newCommitTracker(onCommit func(lastKey, rows int64)) *commitTracker {
+	return &commitTracker{pending: make(map[int64]batchMark), onCommit: onCommit}
+}
+
+// done 记录一个批次已提交，连续前缀推进时回调新的检查点和新增的行数
+func (t *commitTracker) done(seq int64, mark batchMark) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[seq] = mark
+	var advanced bool
+	var lastKey, rows int64
+	for {
+		m, ok := t.pending[t.next]
+		if !ok {
+			break
+		}
+		delete(t.pending, t.next)
+		t.next++
+		advanced = true
+		lastKey = m.lastKey
+		rows += m.rows
+	}
+	if advanced {
+		t.onCommit(lastKey, rows)
+	}
+}
+
+// keyToInt64 将扫描得到的键值转换为 int64
+func keyToInt64(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return x, true
+	case int32:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int8:
+		return int64(x), true
+	case int:
+		return int64(x), true
+	case uint64:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case float64:
+		return int64(x), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(strings.TrimSpace(string(x)), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrorClass 写入错误的类别，重试策略按类别决定是否重试
+type ErrorClass string
+
+const (
+	ErrorClassConnection ErrorClass = "connection" // 连接断开、服务端重启或连接数已满
+	ErrorClassDeadlock   ErrorClass = "deadlock"   // 死锁 (1213)，事务已被回滚
+	ErrorClassLockWait   ErrorClass = "lock_wait"  // 锁等待超时 (1205)
+	ErrorClassTimeout    ErrorClass = "timeout"    // 语句超过本地超时时间被取消
+	ErrorClassOther      ErrorClass = "other"      // 其他错误，如数据错误、语法错误，重试也不会成功
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ErrorClassConnection: "连接错误",
+	ErrorClassDeadlock:   "死锁",
+	ErrorClassLockWait:   "锁等待超时",
+	ErrorClassTimeout:    "语句超时",
+	ErrorClassOther:      "其他错误",
+}
+
+func (c ErrorClass) String() string {
+	if name, ok := errorClassNames[c]; ok {
+		return name
+	}
+	return string(c)
+}
+
+// connectionErrnos 说明连接不可用的错误码，服务端错误和客户端错误 (经代理转发时可能出现) 都包括在内
+var connectionErrnos = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR: Too many connections
+	1053: true, // ER_SERVER_SHUTDOWN
+	1158: true, // ER_NET_READ_ERROR
+	1159: true, // ER_NET_READ_INTERRUPTED
+	1160: true, // ER_NET_ERROR_ON_WRITE
+	1161: true, // ER_NET_WRITE_INTERRUPTED
+	1927: true, // ER_CONNECTION_KILLED
+	2006: true, // CR_SERVER_GONE_ERROR: MySQL server has gone away
+	2013: true, // CR_SERVER_LOST: Lost connection to MySQL server during query
+}
+
+// ClassifyError 按 MySQL 错误码和驱动的哨兵错误判断错误类别
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassOther
+	}
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		switch {
+		case me.Number == 1213:
+			return ErrorClassDeadlock
+		case me.Number == 1205:
+			return ErrorClassLockWait
+		case connectionErrnos[me.Number]:
+			return ErrorClassConnection
+		}
+		return ErrorClassOther
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ErrorClassConnection
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return ErrorClassConnection
+	}
+	return ErrorClassOther
+}
+
+// ParseErrorClasses 解析逗号分隔的错误类别，如 "connection,deadlock,lock_wait"
+func ParseErrorClasses(s string) ([]ErrorClass, error) {
+	var classes []ErrorClass
+	for _, item := range strings.Split(s, ",") {
+		c := ErrorClass(strings.ToLower(strings.TrimSpace(item)))
+		switch c {
+		case "":
+			continue
+		case ErrorClassConnection, ErrorClassDeadlock, ErrorClassLockWait, ErrorClassTimeout:
+			classes = append(classes, c)
+		default:
+			return nil, fmt.Errorf("未知的错误类别 %q (可选: connection, deadlock, lock_wait, timeout)", item)
+		}
+	}
+	return classes, nil
+}
+
+// RetryPolicy 写入失败时的重试策略
+// 每批在一个事务中写入，失败时整个事务回滚后重新执行，重试不会重复写入
+type RetryPolicy struct {
+	MaxAttempts int           // 每批最多执行的次数，含第一次，1 表示不重试
+	BaseDelay   time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 单次等待时间的上限
+	Retryable   []ErrorClass  // 可以重试的错误类别
+}
+
+// DefaultRetryPolicy 默认最多执行 5 次，连接错误、死锁和锁等待超时可以重试
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Retryable:   []ErrorClass{ErrorClassConnection, ErrorClassDeadlock, ErrorClassLockWait},
+	}
+}
+
+// retryable 判断该类错误是否可以重试
+func (p RetryPolicy) retryable(class ErrorClass) bool {
+	for _, c := range p.Retryable {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第 attempt 次失败后的等待时间: 指数增长，取上限后在后一半区间内随机，避免多个写入协程同时重试
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// commitUnknownError 提交时连接断开或超时，无法确定事务是否已提交，不能重试
+type commitUnknownError struct {
+	err error
+}
+
+func (e *commitUnknownError) Error() string {
+	return fmt.Sprintf("提交结果未知 (该批可能已经写入，为避免重复写入不再重试): %v", e.err)
+}
+
+func (e *commitUnknownError) Unwrap() error { return e.err }
+
+// withRetry 在固定连接上以一个事务执行 fn 并提交，失败时按重试策略重新执行整个事务
+// fn 中的语句失败或连接在提交前断开时事务会被回滚，重试不会重复写入；提交时连接断开则不重试
+// 返回重试的次数
+func (pc *pinnedConn) withRetry(policy RetryPolicy, fn func(tx *sql.Tx) error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := pc.runTx(fn)
+		if err == nil {
+			return attempt - 1, nil
+		}
+		class := ClassifyError(err)
+		if class == ErrorClassConnection || class == ErrorClassTimeout {
+			// 连接已不可用，下次换一条新连接
+			pc.Close()
+		}
+		var unknown *commitUnknownError
+		if errors.As(err, &unknown) || !policy.retryable(class) || attempt >= policy.MaxAttempts {
+			return attempt - 1, err
+		}
+		delay := policy.backoff(attempt)
+		log.Printf("⚠️  写入遇到%s，%v 后重试 (%d/%d): %v", class, delay.Round(time.Millisecond), attempt, policy.MaxAttempts-1, err)
+		time.Sleep(delay)
+	}
+}
+
+// runTx 开启事务执行 fn 并提交，fn 失败时回滚
+func (pc *pinnedConn) runTx(fn func(tx *sql.Tx) error) error {
+	tx, err := pc.BeginTx(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		switch ClassifyError(err) {
+		case ErrorClassConnection, ErrorClassTimeout:
+			return &commitUnknownError{err: err}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ErrorClassOther},
+		{"deadlock", &mysql.MySQLError{Number: 1213}, ErrorClassDeadlock},
+		{"lock wait", &mysql.MySQLError{Number: 1205}, ErrorClassLockWait},
+		{"wrapped deadlock", fmt.Errorf("batch: %w", &mysql.MySQLError{Number: 1213}), ErrorClassDeadlock},
+		{"server gone", &mysql.MySQLError{Number: 2006}, ErrorClassConnection},
+		{"too many connections", &mysql.MySQLError{Number: 1040}, ErrorClassConnection},
+		{"duplicate key", &mysql.MySQLError{Number: 1062}, ErrorClassOther},
+		{"data too long", &mysql.MySQLError{Number: 1406}, ErrorClassOther},
+		{"deadline", context.DeadlineExceeded, ErrorClassTimeout},
+		{"bad conn", driver.ErrBadConn, ErrorClassConnection},
+		{"invalid conn", mysql.ErrInvalidConn, ErrorClassConnection},
+		{"conn done", sql.ErrConnDone, ErrorClassConnection},
+		{"unexpected eof", io.ErrUnexpectedEOF, ErrorClassConnection},
+		{"connection reset", fmt.Errorf("write: %w", syscall.ECONNRESET), ErrorClassConnection},
+		{"net error", &net.OpError{Op: "read", Err: errors.New("i/o timeout")}, ErrorClassConnection},
+		{"plain", errors.New("syntax error"), ErrorClassOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError(%v) = %s, want %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrorClasses(t *testing.T) {
+	got, err := ParseErrorClasses(" Connection, deadlock,,lock_wait ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ErrorClass{ErrorClassConnection, ErrorClassDeadlock, ErrorClassLockWait}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ParseErrorClasses = %v, want %v", got, want)
+	}
+	if _, err := ParseErrorClasses("connection,other"); err == nil {
+		t.Error("ParseErrorClasses accepted other")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		ceil    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		// 在上限的后一半区间内随机
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(tt.attempt); d < tt.ceil/2 || d > tt.ceil {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v]", tt.attempt, d, tt.ceil/2, tt.ceil)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("zero policy backoff = %v, want 0", d)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	p := DefaultRetryPolicy()
+	for class, want := range map[ErrorClass]bool{
+		ErrorClassConnection: true,
+		ErrorClassDeadlock:   true,
+		ErrorClassLockWait:   true,
+		ErrorClassTimeout:    false,
+		ErrorClassOther:      false,
+	} {
+		if got := p.retryable(class); got != want {
+			t.Errorf("retryable(%s) = %v, want %v", class, got, want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxScheduleSleep 窗口外单次最长等待时间，到点后重新计算，系统时间被调整时也能及时恢复
+const maxScheduleSleep = time.Minute
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// scheduleWindow 一条时间窗口，start/end 为当天 0 点起的分钟数，跨零点的窗口 end 大于 1440
+type scheduleWindow struct {
+	days  [7]bool // 窗口开始的星期
+	start int
+	end   int
+}
+
+// Schedule 允许读取源库的时间窗口，窗口外读取端在批次之间暂停，窗口重新开启后从暂停处继续
+type Schedule struct {
+	windows []scheduleWindow
+	loc     *time.Location
+
+	mu       sync.Mutex
+	pausedAt time.Time // 当前暂停的开始时间，未暂停时为零值
+	pauses   int
+	paused   time.Duration
+}
+
+// ParseSchedule 解析时间窗口，spec 为空时返回 nil 表示不限制
+// 多个窗口用分号分隔，每个窗口为 "[星期] 开始-结束"，省略星期表示每天，结束早于开始表示跨零点到次日:
+//
+//	22:00-06:00
+//	mon-fri 22:00-06:00; sat,sun 00:00-24:00
+func ParseSchedule(spec string, loc *time.Location) (*Schedule, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &Schedule{loc: loc}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := parseScheduleWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("时间窗口 %q 格式错误: %v", part, err)
+		}
+		s.windows = append(s.windows, w)
+	}
+	if len(s.windows) == 0 {
+		return nil, fmt.Errorf("时间窗口 %q 中没有任何窗口", spec)
+	}
+	return s, nil
+}
+
+func parseScheduleWindow(part string) (scheduleWindow, error) {
+	var w scheduleWindow
+	fields := strings.Fields(part)
+	var days, span string
+	switch len(fields) {
+	case 1:
+		days, span = "*", fields[0]
+	case 2:
+		days, span = fields[0], fields[1]
+	default:
+		return w, fmt.Errorf("应为 \"[星期] 开始-结束\"")
+	}
+
+	if err := parseScheduleDays(strings.ToLower(days), &w.days); err != nil {
+		return w, err
+	}
+
+	from, to, ok := strings.Cut(span, "-")
+	if !ok {
+		return w, fmt.Errorf("时间段应为 开始-结束")
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return w, err
+	}
+	switch {
+	case w.start == 24*60:
+		return w, fmt.Errorf("开始时间不能是 24:00")
+	case w.end == w.start:
+		return w, fmt.Errorf("开始和结束时间相同，全天请写 00:00-24:00")
+	case w.end < w.start:
+		w.end += 24 * 60
+	}
+	return w, nil
+}
+
+// parseScheduleDays 解析星期，支持 *、mon、mon-fri、sat,sun 及其组合，范围可以跨周日如 fri-mon
+func parseScheduleDays(spec string, days *[7]bool) error {
+	if spec == "*" || spec == "daily" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, ok := weekdayNames[from]
+		if !ok {
+			return fmt.Errorf("未知的星期 %q (可选: mon, tue, wed, thu, fri, sat, sun)", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdayNames[to]; !ok {
+				return fmt.Errorf("未知的星期 %q (可选: mon, tue, wed, thu, fri, sat, sun)", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock 解析 HH:MM，返回 0 点起的分钟数，允许 24:00
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, err1 := strconv.Atoi(hh)
+	m, err2 := strconv.Atoi(mm)
+	if !ok || err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("时间 %q 应为 HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// instances 对 t 所在日期前一天到七天后的每一天，依次回调当天开始的窗口
+func (s *Schedule) instances(t time.Time, fn func(start, end time.Time)) {
+	t = t.In(s.loc)
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, s.loc)
+		for _, w := range s.windows {
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, s.loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), 0, w.end, 0, 0, s.loc)
+			fn(start, end)
+		}
+	}
+}
+
+// Open 判断 t 是否在某个时间窗口内
+func (s *Schedule) Open(t time.Time) bool {
+	open := false
+	s.instances(t, func(start, end time.Time) {
+		if !t.Before(start) && t.Before(end) {
+			open = true
+		}
+	})
+	return open
+}
+
+// Closed 判断 t 是否在所有窗口之外，s 为空时返回 false
+func (s *Schedule) Closed(t time.Time) bool {
+	return s != nil && !s.Open(t)
+}
+
+// NextOpen 返回 t 之后最近一个窗口的开始时间
+func (s *Schedule) NextOpen(t time.Time) time.Time {
+	var next time.Time
+	s.instances(t, func(start, end time.Time) {
+		if start.After(t) && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	})
+	return next
+}
+
+// Wait 在批次之间调用: 窗口内立即返回，窗口外等到下一个窗口开启
+// 返回等待的时间，s 为空时不等待，ctx 结束时提前返回 ctx 的错误
+func (s *Schedule) Wait(ctx context.Context) (time.Duration, error) {
+	if s == nil {
+		return 0, nil
+	}
+	start := time.Now()
+	for {
+		now := time.Now()
+		if s.Open(now) {
+			s.resume(now)
+			return now.Sub(start), nil
+		}
+		next := s.NextOpen(now)
+		s.pause(now, next)
+
+		sleep := min(next.Sub(now), maxScheduleSleep)
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Since(start), ctx.Err()
+		}
+	}
+}
+
+// pause 记录暂停开始，多个协程同时暂停只记一次
+func (s *Schedule) pause(now, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pausedAt.IsZero() {
+		return
+	}
+	s.pausedAt = now
+	s.pauses++
+	log.Printf("⏰ 当前不在迁移时间窗口内，完成手头的批次后暂停，预计 %s 恢复", next.In(s.loc).Format("2006-01-02 15:04"))
+}
+
+// resume 记录暂停结束
+func (s *Schedule) resume(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pausedAt.IsZero() {
+		return
+	}
+	d := now.Sub(s.pausedAt)
+	s.paused += d
+	s.pausedAt = time.Time{}
+	log.Printf("⏰ 迁移时间窗口已开启，从暂停处继续 (本次暂停 %v)", d.Round(time.Second))
+}
+
+// WithActiveTimeout 返回在窗口内累计运行 d 后超时的上下文，窗口外暂停的时间不计入
+// 超时后 context.Cause 返回 context.DeadlineExceeded；s 为空时等同 context.WithTimeout
+func (s *Schedule) WithActiveTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if s == nil {
+		return context.WithTimeout(parent, d)
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	start := time.Now()
+	_, pausedBefore := s.Pauses()
+	go func() {
+		for {
+			_, paused := s.Pauses()
+			remaining := d - (time.Since(start) - (paused - pausedBefore))
+			if remaining <= 0 {
+				cancel(context.DeadlineExceeded)
+				return
+			}
+			// 暂停期间剩余时间不减少，到点后重新计算
+			timer := time.NewTimer(remaining)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// Pauses 返回窗口外暂停的次数和累计时间
+func (s *Schedule) Pauses() (int, time.Duration) {
+	if s == nil {
+		return 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.paused
+	if !s.pausedAt.IsZero() {
+		d += time.Since(s.pausedAt)
+	}
+	return s.pauses, d
+}
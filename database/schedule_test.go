@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		windows int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"22:00-06:00", 1, false},
+		{"mon-fri 22:00-06:00; sat,sun 00:00-24:00", 2, false},
+		{"fri-mon 01:00-02:00", 1, false},
+		{"22:00", 0, true},
+		{"xyz 22:00-06:00", 0, true},
+		{"08:00-08:00", 0, true},
+		{"24:00-06:00", 0, true},
+		{"25:00-06:00", 0, true},
+		{" ; ", 0, true},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec, time.UTC)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s != nil && len(s.windows) != tt.windows {
+			t.Errorf("ParseSchedule(%q) = %d windows, want %d", tt.spec, len(s.windows), tt.windows)
+		}
+	}
+}
+
+func TestScheduleOpen(t *testing.T) {
+	s, err := ParseSchedule("mon-fri 22:00-06:00; sat,sun 00:00-24:00", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-01-01 是星期一
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		t    time.Time
+		open bool
+		next time.Time
+	}{
+		{"monday noon", at(1, 12, 0), false, at(1, 22, 0)},
+		{"monday night", at(1, 23, 0), true, time.Time{}},
+		{"tuesday early", at(2, 5, 59), true, time.Time{}},
+		{"tuesday window end", at(2, 6, 0), false, at(2, 22, 0)},
+		{"friday night into saturday", at(6, 3, 0), true, time.Time{}},
+		{"sunday", at(7, 15, 0), true, time.Time{}},
+		{"monday morning after weekend", at(8, 6, 30), false, at(8, 22, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Open(tt.t); got != tt.open {
+				t.Errorf("Open(%v) = %v, want %v", tt.t, got, tt.open)
+			}
+			if got := s.Closed(tt.t); got == tt.open {
+				t.Errorf("Closed(%v) = %v, want %v", tt.t, got, !tt.open)
+			}
+			if !tt.open {
+				if got := s.NextOpen(tt.t); !got.Equal(tt.next) {
+					t.Errorf("NextOpen(%v) = %v, want %v", tt.t, got, tt.next)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleWait(t *testing.T) {
+	var none *Schedule
+	if waited, err := none.Wait(context.Background()); waited != 0 || err != nil {
+		t.Errorf("nil schedule Wait = %v, %v", waited, err)
+	}
+	if none.Closed(time.Now()) {
+		t.Error("nil schedule should never be closed")
+	}
+
+	always, _ := ParseSchedule("00:00-24:00", time.UTC)
+	if _, err := always.Wait(context.Background()); err != nil {
+		t.Errorf("open schedule Wait error = %v", err)
+	}
+
+	// 窗口之外等待时，ctx 结束应立即返回
+	now := time.Now().UTC()
+	from := now.Add(2 * time.Hour)
+	spec := from.Format("15:04") + "-" + from.Add(time.Hour).Format("15:04")
+	closed, err := ParseSchedule(spec, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := closed.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("closed schedule Wait error = %v, want deadline exceeded", err)
+	}
+	if pauses, _ := closed.Pauses(); pauses != 1 {
+		t.Errorf("Pauses = %d, want 1", pauses)
+	}
+}
+
+func TestWithActiveTimeout(t *testing.T) {
+	var none *Schedule
+	ctx, cancel := none.WithActiveTimeout(context.Background(), 10*time.Millisecond)
+	<-ctx.Done()
+	cancel()
+	if !errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		t.Errorf("nil schedule cause = %v", context.Cause(ctx))
+	}
+
+	s, _ := ParseSchedule("00:00-24:00", time.UTC)
+	ctx, cancel = s.WithActiveTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	// 暂停期间不计入超时
+	s.pause(time.Now(), time.Now().Add(time.Hour))
+	select {
+	case <-ctx.Done():
+		t.Fatal("timed out while paused")
+	case <-time.After(80 * time.Millisecond):
+	}
+	s.resume(time.Now())
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("did not time out after resume")
+	}
+	if !errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		t.Errorf("cause = %v, want deadline exceeded", context.Cause(ctx))
+	}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SessionSettings 每条新连接建立后的会话设置，零值表示保持服务端默认
+type SessionSettings struct {
+	DisableForeignKeyChecks bool     // SET FOREIGN_KEY_CHECKS = 0
+	DisableUniqueChecks     bool     // SET UNIQUE_CHECKS = 0
+	SQLMode                 string   // SET SESSION sql_mode
+	TimeZone                string   // SET time_zone，只影响 TIMESTAMP 列
+	DisableBinlog           bool     // SET sql_log_bin = 0，需要 SUPER 或 SYSTEM_VARIABLES_ADMIN 权限，写入不会复制到从库
+	LockWaitTimeout         int      // SET SESSION innodb_lock_wait_timeout，单位秒
+	Extra                   []string // 额外执行的语句
+}
+
+// Statements 返回需要在每条新连接上执行的语句
+func (s SessionSettings) Statements() []string {
+	var stmts []string
+	if s.DisableForeignKeyChecks {
+		stmts = append(stmts, "SET FOREIGN_KEY_CHECKS = 0")
+	}
+	if s.DisableUniqueChecks {
+		stmts = append(stmts, "SET UNIQUE_CHECKS = 0")
+	}
+	if s.SQLMode != "" {
+		stmts = append(stmts, "SET SESSION sql_mode = "+quoteString(s.SQLMode))
+	}
+	if s.TimeZone != "" {
+		stmts = append(stmts, "SET time_zone = "+quoteString(s.TimeZone))
+	}
+	if s.DisableBinlog {
+		stmts = append(stmts, "SET sql_log_bin = 0")
+	}
+	if s.LockWaitTimeout > 0 {
+		stmts = append(stmts, fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", s.LockWaitTimeout))
+	}
+	for _, stmt := range s.Extra {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// quoteString 生成 MySQL 字符串字面量
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// sessionConnector 包装驱动的 Connector，每条新连接建立后依次执行会话初始化语句
+// 连接池中的每条连接都会执行，不会出现只有某一条连接生效的情况
+type sessionConnector struct {
+	driver.Connector
+	init []string
+}
+
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok && len(c.init) > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("驱动连接不支持执行会话初始化语句")
+	}
+	for _, stmt := range c.init {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("执行会话初始化语句 %q 失败: %v", stmt, err)
+		}
+	}
+	return conn, nil
+}
+
+// openMySQL 打开连接池，sessionInit 为每条新连接执行的语句
+// foundRows 为 true 时开启 clientFoundRows，UPDATE 和 upsert 按匹配的行而不是实际变化的行计 affected rows
+func openMySQL(dsn string, sessionInit []string, foundRows bool) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if foundRows {
+		cfg.ClientFoundRows = true
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&sessionConnector{Connector: connector, init: sessionInit}), nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+)
+
+// shadowSuffix 影子表后缀
+const shadowSuffix = "__new"
+
+// ShadowTableName 返回表的影子表名: <表名>__new
+func ShadowTableName(tableName string) string {
+	return tableName + shadowSuffix
+}
+
+// RetiredTableName 返回影子表切换后被换下的旧表名: <表名>__old
+func RetiredTableName(tableName string) string {
+	return tableName + "__old"
+}
+
+// CreateTableLike 按已有表的结构创建新表（保留索引、字符集等定义）
+func (mc *MySQLConnector) CreateTableLike(tableName, likeTable string) error {
+	_, err := mc.db.Exec(fmt.Sprintf("CREATE TABLE `%s` LIKE `%s`", tableName, likeTable))
+	if err != nil {
+		return fmt.Errorf("create table like error: %v", err)
+	}
+	return nil
+}
+
+// CountRows 统计表的行数
+func (mc *MySQLConnector) CountRows(tableName string) (int64, error) {
+	var count int64
+	err := mc.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`", tableName)).Scan(&count)
+	return count, err
+}
+
+// SwapShadowTable 用影子表替换正式表
+// 正式表存在时通过一条 RENAME TABLE 同时完成 "旧表换下、影子表换上"，读者只会看到完整的旧表或新表；
+// 正式表不存在时直接把影子表重命名为正式表
+func (mc *MySQLConnector) SwapShadowTable(tableName, shadowName, retiredName string) error {
+	exists, err := mc.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return mc.RenameTable(shadowName, tableName)
+	}
+
+	if len(retiredName) > mysqlMaxIdentLen {
+		return fmt.Errorf("表名 %s 超过 MySQL 表名长度限制 %d", retiredName, mysqlMaxIdentLen)
+	}
+	_, err = mc.db.Exec(fmt.Sprintf("RENAME TABLE `%s` TO `%s`, `%s` TO `%s`",
+		tableName, retiredName, shadowName, tableName))
+	if err != nil {
+		return fmt.Errorf("swap table error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CurrentLSN 返回达梦当前的 LSN 和数据库时间
+func (dmc *DMConnector) CurrentLSN() (int64, time.Time, error) {
+	var lsn int64
+	var now time.Time
+	err := dmc.db.QueryRow("SELECT CUR_LSN, SYSDATE FROM V$RLOG").Scan(&lsn, &now)
+	return lsn, now, err
+}
+
+// FlashbackEnabled 检查是否开启了闪回 (dm.ini 中的 ENABLE_FLASHBACK)
+func (dmc *DMConnector) FlashbackEnabled() (bool, error) {
+	var v string
+	err := dmc.db.QueryRow("SELECT PARA_VALUE FROM V$DM_INI WHERE PARA_NAME = 'ENABLE_FLASHBACK'").Scan(&v)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(v) == "1", nil
+}
+
+// SetSnapshot 设置一致性快照点，之后迁移相关的读取都通过闪回查询读取该 LSN 时的数据，0 表示读取最新数据
+// 变更捕获的回源查询需要当前数据，不受影响
+func (dmc *DMConnector) SetSnapshot(lsn int64) {
+	dmc.snapshotLSN = lsn
+}
+
+// Snapshot 返回当前的快照点，未设置时为 0
+func (dmc *DMConnector) Snapshot() int64 {
+	return dmc.snapshotLSN
+}
+
+// readFrom 返回读取表数据时 FROM 子句中的表引用，设置了快照点时附带闪回子句
+func (dmc *DMConnector) readFrom(realTableName string) string {
+	if dmc.snapshotLSN == 0 {
+		return realTableName
+	}
+	return fmt.Sprintf("%s AS OF SCN %d", realTableName, dmc.snapshotLSN)
+}
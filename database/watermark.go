@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WatermarkKind 水位列的类型
+type WatermarkKind string
+
+const (
+	WatermarkTime   WatermarkKind = "time"   // DATE / TIMESTAMP 等时间列，如 UPDATE_TIME
+	WatermarkNumber WatermarkKind = "number" // 整数列，如 VERSION 或自增 ID
+)
+
+// WatermarkKindOf 根据列类型判断能否作为水位列
+func WatermarkKindOf(col DMColumn) (WatermarkKind, error) {
+	t := strings.ToUpper(strings.TrimSpace(col.DataType))
+	switch {
+	case isIntegerColumn(col):
+		return WatermarkNumber, nil
+	case t == "DATE", t == "DATETIME", strings.HasPrefix(t, "TIMESTAMP"):
+		return WatermarkTime, nil
+	}
+	return "", fmt.Errorf("列 %s 的类型 %s 不能作为水位列 (需要整数或时间类型)", col.Name, col.DataType)
+}
+
+// Parse 解析状态文件中保存的水位值
+func (k WatermarkKind) Parse(s string) (interface{}, error) {
+	if k == WatermarkTime {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Format 将水位值格式化后保存到状态文件
+func (k WatermarkKind) Format(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// DefaultOverlap 未配置重叠区间时的默认值: 时间列回退 5 分钟，整数列不回退
+func (k WatermarkKind) DefaultOverlap() string {
+	if k == WatermarkTime {
+		return "5m"
+	}
+	return "0"
+}
+
+// CheckOverlap 校验重叠区间与水位列类型是否匹配，时间列应为时长 (如 5m)，整数列应为非负整数，为空时使用默认值
+func (k WatermarkKind) CheckOverlap(overlap string) error {
+	_, _, err := k.parseOverlap(overlap)
+	return err
+}
+
+// parseOverlap 解析重叠区间，时间列返回时长，整数列返回整数
+func (k WatermarkKind) parseOverlap(overlap string) (time.Duration, int64, error) {
+	overlap = strings.TrimSpace(overlap)
+	if overlap == "" {
+		overlap = k.DefaultOverlap()
+	}
+	if k == WatermarkTime {
+		d, err := time.ParseDuration(overlap)
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("时间水位的重叠区间应为非负的时长 (如 5m)，而不是 %q", overlap)
+		}
+		return d, 0, nil
+	}
+	n, err := strconv.ParseInt(overlap, 10, 64)
+	if err != nil || n < 0 {
+		return 0, 0, fmt.Errorf("整数水位的重叠区间应为非负整数 (如 1000)，而不是 %q", overlap)
+	}
+	return 0, n, nil
+}
+
+// Rewind 将水位回退 overlap，容忍源库时钟偏差和晚提交的事务
+// 时间列的 overlap 为时长 (如 5m)，整数列的 overlap 为整数，为空时使用 DefaultOverlap，无法解析时报错
+func (k WatermarkKind) Rewind(v interface{}, overlap string) (interface{}, error) {
+	d, n, err := k.parseOverlap(overlap)
+	if err != nil {
+		return nil, err
+	}
+	if k == WatermarkTime {
+		return v.(time.Time).Add(-d), nil
+	}
+	return v.(int64) - n, nil
+}
+
+// MaxWatermark 读取源表水位列的当前最大值，表为空时返回 nil
+func (dmc *DMConnector) MaxWatermark(tableName, column string, kind WatermarkKind) (interface{}, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteDMIdent(column), dmc.readFrom(realTableName))
+
+	if kind == WatermarkTime {
+		var v sql.NullTime
+		if err := dmc.db.QueryRow(query).Scan(&v); err != nil {
+			return nil, err
+		}
+		if !v.Valid {
+			return nil, nil
+		}
+		return v.Time, nil
+	}
+
+	var v sql.NullInt64
+	if err := dmc.db.QueryRow(query).Scan(&v); err != nil {
+		return nil, err
+	}
+	if !v.Valid {
+		return nil, nil
+	}
+	return v.Int64, nil
+}
+
+// GetTableDataSince 读取水位列在 (from, to] 区间内的行，按水位列升序
+// from 为空表示首次同步，同时读取水位列为 NULL 的行
+func (dmc *DMConnector) GetTableDataSince(tableName string, cols []string, column string, from, to interface{}) (*sql.Rows, error) {
+	realTableName := dmc.getRealTableName(tableName)
+	col := quoteDMIdent(column)
+
+	var query string
+	var args []interface{}
+	if from == nil {
+		query = fmt.Sprintf("SELECT %s FROM %s WHERE %s <= ? OR %s IS NULL ORDER BY %s", selectList(cols), dmc.readFrom(realTableName), col, col, col)
+		args = []interface{}{to}
+	} else {
+		query = fmt.Sprintf("SELECT %s FROM %s WHERE %s > ? AND %s <= ? ORDER BY %s", selectList(cols), dmc.readFrom(realTableName), col, col, col)
+		args = []interface{}{from, to}
+	}
+	log.Printf("📥 开始增量读取表 %s 的数据", tableName)
+	return dmc.db.Query(query, args...)
+}
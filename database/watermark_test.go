@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatermarkRewind(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		kind    WatermarkKind
+		v       interface{}
+		overlap string
+		want    interface{}
+		wantErr bool
+	}{
+		{"time default", WatermarkTime, base, "", base.Add(-5 * time.Minute), false},
+		{"time explicit", WatermarkTime, base, "10m", base.Add(-10 * time.Minute), false},
+		{"time zero", WatermarkTime, base, "0s", base, false},
+		{"time integer", WatermarkTime, base, "1000", nil, true},
+		{"time negative", WatermarkTime, base, "-5m", nil, true},
+		{"number default", WatermarkNumber, int64(500), "", int64(500), false},
+		{"number explicit", WatermarkNumber, int64(500), "100", int64(400), false},
+		{"number duration", WatermarkNumber, int64(500), "5m", nil, true},
+		{"number negative", WatermarkNumber, int64(500), "-1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.kind.Rewind(tt.v, tt.overlap)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rewind(%v, %q) error = %v, wantErr %v", tt.v, tt.overlap, err, tt.wantErr)
+			}
+			if err := tt.kind.CheckOverlap(tt.overlap); (err != nil) != tt.wantErr {
+				t.Errorf("CheckOverlap(%q) error = %v, wantErr %v", tt.overlap, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Rewind(%v, %q) = %v, want %v", tt.v, tt.overlap, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WriteMode 批量写入方式
+type WriteMode string
+
+const (
+	WriteModeInsert  WriteMode = "insert"  // INSERT，遇到重复键整批失败 (默认)
+	WriteModeIgnore  WriteMode = "ignore"  // INSERT IGNORE，跳过重复键的行
+	WriteModeReplace WriteMode = "replace" // REPLACE，删除冲突的旧行后插入
+	WriteModeUpsert  WriteMode = "upsert"  // INSERT ... ON DUPLICATE KEY UPDATE，用新值更新冲突的旧行
+)
+
+// ParseWriteMode 解析写入方式字符串
+func ParseWriteMode(s string) (WriteMode, error) {
+	switch m := WriteMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case WriteModeInsert, WriteModeIgnore, WriteModeReplace, WriteModeUpsert:
+		return m, nil
+	default:
+		return "", fmt.Errorf("未知的写入方式 %q (可选: insert, ignore, replace, upsert)", s)
+	}
+}
+
+// verb 返回写入语句的开头部分
+func (m WriteMode) verb() string {
+	switch m {
+	case WriteModeIgnore:
+		return "INSERT IGNORE INTO"
+	case WriteModeReplace:
+		return "REPLACE INTO"
+	default:
+		return "INSERT INTO"
+	}
+}
+
+// account 根据一批语句的 affected rows 累计插入/更新/忽略行数
+// MySQL 的 affected rows 规则:
+//   - INSERT IGNORE: 每插入一行计 1，被忽略的行计 0
+//   - REPLACE: 新插入的行计 1，替换旧行计 2 (先删后插)
+//   - ON DUPLICATE KEY UPDATE: 新插入的行计 1，更新的行计 2；
+//     批量写入的连接池开启了 clientFoundRows，值未变化的行也计 1，与新插入的行无法区分，合并计入 InsertedOrSame
+func (m WriteMode) account(stats *InsertStats, rows, affected int64) {
+	switch m {
+	case WriteModeIgnore:
+		stats.Inserted += affected
+		stats.Ignored += rows - affected
+	case WriteModeReplace, WriteModeUpsert:
+		updated := min(max(affected-rows, 0), rows)
+		stats.Updated += updated
+		if m == WriteModeUpsert {
+			stats.InsertedOrSame += rows - updated
+		} else {
+			stats.Inserted += rows - updated
+		}
+	default:
+		stats.Inserted += affected
+	}
+}
+
+// supportsRowAlias 判断服务端是否支持 8.0.20 引入的 INSERT ... AS alias 语法
+// (VALUES() 函数在 8.0.20 起被标记为废弃)
+func (mc *MySQLConnector) supportsRowAlias() bool {
+	if strings.Contains(strings.ToLower(mc.serverVersion), "mariadb") {
+		return false
+	}
+	parts := strings.SplitN(mc.serverVersion, ".", 3)
+	if len(parts) < 3 {
+		return false
+	}
+	major, _ := strconv.Atoi(parts[0])
+	minor, _ := strconv.Atoi(parts[1])
+	patch, _ := strconv.Atoi(strings.SplitN(parts[2], "-", 2)[0])
+	switch {
+	case major != 8:
+		return major > 8
+	case minor != 0:
+		return minor > 0
+	default:
+		return patch >= 20
+	}
+}
+
+// upsertClause 生成 ON DUPLICATE KEY UPDATE 子句
+// 只更新非主键列；若所有列都是主键，则用主键列给自己赋值，使冲突行保持不变
+func (mc *MySQLConnector) upsertClause(columns []MySQLColumn) string {
+	var targets []MySQLColumn
+	for _, col := range columns {
+		if !col.IsPrimaryKey {
+			targets = append(targets, col)
+		}
+	}
+	if len(targets) == 0 {
+		targets = columns
+	}
+
+	alias := mc.supportsRowAlias()
+	sets := make([]string, len(targets))
+	for i, col := range targets {
+		if alias {
+			sets[i] = fmt.Sprintf("`%s` = `_src`.`%s`", col.Name, col.Name)
+		} else {
+			sets[i] = fmt.Sprintf("`%s` = VALUES(`%s`)", col.Name, col.Name)
+		}
+	}
+
+	clause := " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	if alias {
+		clause = " AS `_src`" + clause
+	}
+	return clause
+}
@@ -0,0 +1,32 @@
+package database
+
+import "testing"
+
+func TestWriteModeAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     WriteMode
+		rows     int64
+		affected int64
+		want     InsertStats
+	}{
+		{"insert", WriteModeInsert, 10, 10, InsertStats{Inserted: 10}},
+		{"ignore skips duplicates", WriteModeIgnore, 10, 7, InsertStats{Inserted: 7, Ignored: 3}},
+		{"replace all new", WriteModeReplace, 10, 10, InsertStats{Inserted: 10}},
+		{"replace some", WriteModeReplace, 10, 13, InsertStats{Inserted: 7, Updated: 3}},
+		{"replace multiple unique keys", WriteModeReplace, 2, 6, InsertStats{Updated: 2}},
+		// 3 行更新计 6，2 行新插入或值未变化计 2
+		{"upsert mixed", WriteModeUpsert, 5, 8, InsertStats{Updated: 3, InsertedOrSame: 2}},
+		{"upsert unchanged", WriteModeUpsert, 4, 4, InsertStats{InsertedOrSame: 4}},
+		{"upsert all updated", WriteModeUpsert, 4, 8, InsertStats{Updated: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got InsertStats
+			tt.mode.account(&got, tt.rows, tt.affected)
+			if got != tt.want {
+				t.Errorf("account(%d, %d) = %+v, want %+v", tt.rows, tt.affected, got, tt.want)
+			}
+		})
+	}
+}
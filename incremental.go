@@ -0,0 +1,217 @@
+package main
+
+import (
+	"dm2mysql-migrator/checkpoint"
+	"dm2mysql-migrator/config"
+	"dm2mysql-migrator/database"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// tableWatermarkOverlap 返回表的水位重叠区间，表级配置优先于 -watermark-overlap 参数
+func tableWatermarkOverlap(table config.TableConfig) string {
+	if table.WatermarkOverlap != "" {
+		return table.WatermarkOverlap
+	}
+	return *watermarkOverlap
+}
+
+// checkWatermarkOverlaps 校验每张增量同步表的重叠区间与水位列类型是否匹配
+// 结构读取失败或水位列不存在的表跳过，由同步时报告错误
+func checkWatermarkOverlaps(dm *database.DMConnector, tables []config.TableConfig) error {
+	for _, table := range tables {
+		if table.Watermark == "" {
+			continue
+		}
+		dmCols, err := dm.GetTableSchema(table.Name)
+		if err != nil {
+			continue
+		}
+		for _, col := range dmCols {
+			if !strings.EqualFold(col.Name, table.Watermark) {
+				continue
+			}
+			kind, err := database.WatermarkKindOf(col)
+			if err != nil {
+				return fmt.Errorf("表 %s 配置错误: %v", table.Name, err)
+			}
+			if err := kind.CheckOverlap(tableWatermarkOverlap(table)); err != nil {
+				return fmt.Errorf("表 %s 配置错误: 水位列 %s: %v", table.Name, col.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateIncremental 按水位列增量同步一张表
+// 先记下源表水位列的当前最大值作为本次上界，再读取 (上次水位 - 重叠区间, 上界] 内的行并 upsert 到目标表，
+// 成功后才把上界保存为新水位。上界之后新写入的行由下一次同步读取；源表删除的行不会同步
+func migrateIncremental(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, table config.TableConfig, report *migrationReport, startTime time.Time) error {
+	tableName := table.Name
+	report.update(tableName, func(res *tableResult) { res.Action = "incremental" })
+
+	dmCols, err := dm.GetTableSchema(tableName)
+	if err != nil {
+		log.Printf("[Worker %d] ❌ 获取结构失败 %s: %v", workerID, tableName, err)
+		return err
+	}
+
+	var wmCol *database.DMColumn
+	var hasPK bool
+	for i, col := range dmCols {
+		if strings.EqualFold(col.Name, table.Watermark) {
+			wmCol = &dmCols[i]
+		}
+		hasPK = hasPK || col.IsPrimaryKey
+	}
+	if wmCol == nil {
+		return fmt.Errorf("表 %s 不存在水位列 %s", tableName, table.Watermark)
+	}
+	if !hasPK {
+		return fmt.Errorf("表 %s 没有主键，增量同步无法以 upsert 方式合并", tableName)
+	}
+	kind, err := database.WatermarkKindOf(*wmCol)
+	if err != nil {
+		return err
+	}
+
+	// 上次同步的水位，列或类型变化后视为首次同步
+	var from interface{}
+	if wm, ok := store.Watermark(tableName); ok && wm.Column == wmCol.Name && wm.Kind == string(kind) {
+		last, err := kind.Parse(wm.Value)
+		if err != nil {
+			return fmt.Errorf("解析表 %s 的水位 %q 失败: %v", tableName, wm.Value, err)
+		}
+		from, err = kind.Rewind(last, tableWatermarkOverlap(table))
+		if err != nil {
+			return fmt.Errorf("表 %s 配置错误: %v", tableName, err)
+		}
+	}
+
+	to, err := dm.MaxWatermark(tableName, wmCol.Name, kind)
+	if err != nil {
+		return fmt.Errorf("读取表 %s 的水位失败: %v", tableName, err)
+	}
+	if to == nil {
+		log.Printf("[Worker %d] ⏭️  表 %s 为空，无需增量同步", workerID, tableName)
+		report.setStatus(tableName, statusCompleted)
+		return nil
+	}
+
+	mysqlCols, err := tableOffload(table, toMySQLColumns(dmCols))
+	if err != nil {
+		return err
+	}
+	exists, err := mysql.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		log.Printf("[Worker %d] 🛠️  正在创建表 %s", workerID, tableName)
+		if err := mysql.CreateTable(tableName, mysqlCols); err != nil {
+			log.Printf("[Worker %d] ❌ 建表失败 %s: %v", workerID, tableName, err)
+			return err
+		}
+	} else if err := mysql.CheckTableCompatible(tableName, mysqlCols); err != nil {
+		log.Printf("[Worker %d] ❌ 目标表 %s 结构不兼容: %v", workerID, tableName, err)
+		return err
+	}
+
+	if from == nil {
+		log.Printf("[Worker %d] 🔖 表 %s 首次增量同步，读取 %s <= %s 的全部行", workerID, tableName, wmCol.Name, kind.Format(to))
+	} else {
+		log.Printf("[Worker %d] 🔖 表 %s 增量同步 %s ∈ (%s, %s]", workerID, tableName, wmCol.Name, kind.Format(from), kind.Format(to))
+	}
+
+	// 重叠区间内的行会被重复读取，只能用 upsert 合并
+	if mode, err := tableWriteMode(table); err == nil && mode != database.WriteModeUpsert {
+		log.Printf("[Worker %d] 💡 表 %s 增量同步使用 upsert 写入 (忽略写入方式 %s)", workerID, tableName, mode)
+	}
+	ld, err := tableLoader(table)
+	if err != nil {
+		return err
+	}
+	opts := database.InsertOptions{
+		BatchSize: *batchSize,
+		WriteMode: database.WriteModeUpsert,
+		Writers:   tableWriters(table),
+		Loader:    ld,
+
+		AdaptiveBatch: tableAdaptiveBatch(table),
+		TargetLatency: *targetLatency,
+
+		Memory: memBudget,
+		Guard:  loadGuard,
+
+		Schedule: schedule,
+		Retry:    retryPolicy,
+	}
+	opts.ReadLimit, opts.WriteLimit = tableThrottles(tableName)
+	if *deadLetterDir != "" {
+		opts.DeadLetter = database.NewDeadLetter(*deadLetterDir, tableName, tableErrorBudget(table))
+		defer opts.DeadLetter.Close()
+	}
+	// 增量同步只追加清单，同一行的新版本以最后一条记录为准
+	opts.Offload, err = offloaderFor(table, true)
+	if err != nil {
+		return fmt.Errorf("打开表 %s 的外存清单失败: %v", tableName, err)
+	}
+	defer opts.Offload.Close()
+
+	rows, err := dm.GetTableDataSince(tableName, database.ColumnNames(mysqlCols), wmCol.Name, from, to)
+	if err != nil {
+		log.Printf("[Worker %d] ❌ 读数据失败 %s: %v", workerID, tableName, err)
+		return err
+	}
+	defer rows.Close()
+
+	stats, err := mysql.BatchInsertData(tableName, mysqlCols, rows, opts)
+	report.update(tableName, func(res *tableResult) {
+		res.Stats = stats
+		if stats.Failed > 0 {
+			res.DeadLetter = opts.DeadLetter.Path()
+		}
+	})
+	if err != nil {
+		log.Printf("[Worker %d] ❌ 写数据失败 %s: %v", workerID, tableName, err)
+		return err
+	}
+
+	if err := store.SetWatermark(tableName, checkpoint.Watermark{
+		Column: wmCol.Name,
+		Kind:   string(kind),
+		Value:  kind.Format(to),
+	}); err != nil {
+		return fmt.Errorf("保存表 %s 的水位失败: %v", tableName, err)
+	}
+
+	log.Printf("[Worker %d] ✅ %s 增量同步完成 (%d 行, %s, 新水位 %s, 耗时: %v)",
+		workerID, tableName, stats.Rows, stats.Breakdown(), kind.Format(to), time.Since(startTime))
+	report.setStatus(tableName, statusCompleted)
+	return nil
+}
+
+// saveSnapshotWatermark 把水位列在快照点时的最大值保存为表的水位
+func saveSnapshotWatermark(dm *database.DMConnector, table config.TableConfig, dmCols []database.DMColumn) error {
+	for _, col := range dmCols {
+		if !strings.EqualFold(col.Name, table.Watermark) {
+			continue
+		}
+		kind, err := database.WatermarkKindOf(col)
+		if err != nil {
+			return err
+		}
+		v, err := dm.MaxWatermark(table.Name, col.Name, kind)
+		if err != nil || v == nil {
+			return err
+		}
+		return store.SetWatermark(table.Name, checkpoint.Watermark{
+			Column: col.Name,
+			Kind:   string(kind),
+			Value:  kind.Format(v),
+		})
+	}
+	return fmt.Errorf("表 %s 不存在水位列 %s", table.Name, table.Watermark)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"dm2mysql-migrator/checkpoint"
 	"dm2mysql-migrator/config"
 	"dm2mysql-migrator/database"
 	"flag"
@@ -31,14 +32,132 @@ var (
 	mysqlVer   = flag.Int("mysql-ver", 5, "MySQL版本: 5 (代表5.0-5.7) 或 8 (代表8.0+)") // 新增参数
 	mysqlExtra = flag.String("mysql-extra", "", "MySQL额外参数")
 
+	// --- MySQL 会话初始化 (连接池中每条新连接都执行) ---
+	fkChecks        = flag.Bool("fk-checks", false, "保留外键检查，默认每条连接 SET FOREIGN_KEY_CHECKS = 0，表可以按任意顺序导入")
+	uniqueChecks    = flag.Bool("unique-checks", true, "保留唯一索引检查，设为 false 时全量导入的连接 SET UNIQUE_CHECKS = 0 (需确保数据没有重复，CDC 和增量同步不受影响)")
+	sqlMode         = flag.String("sql-mode", "", "会话 sql_mode，为空则使用服务端默认")
+	timeZone        = flag.String("time-zone", "", "会话 time_zone，如 +08:00，只影响 TIMESTAMP 列，为空则使用服务端默认")
+	disableBinlog   = flag.Bool("disable-binlog", false, "SET sql_log_bin = 0，写入不记 binlog、不复制到从库 (需要 SUPER 或 SYSTEM_VARIABLES_ADMIN 权限)")
+	lockWaitTimeout = flag.Int("lock-wait-timeout", 0, "会话 innodb_lock_wait_timeout (秒)，0 表示使用服务端默认")
+	sessionInit     = flag.String("session-init", "", "额外的会话初始化语句，多条用分号分隔")
+
 	// --- 全局 ---
 	workerNum = flag.Int("workers", 4, "并发数")
-	batchSize = flag.Int("batch", 2000, "批量大小")
+	batchSize = flag.Int("batch", 2000, "批量大小，开启 -adaptive-batch 时作为初始值")
+	writerNum = flag.Int("writers", 1, "每张表(每个分片)的写入协程数，每个协程使用独立的 MySQL 连接")
+	writeMode = flag.String("write-mode", "insert", "写入方式: insert, ignore (INSERT IGNORE), replace (REPLACE), upsert (ON DUPLICATE KEY UPDATE)")
+	loader    = flag.String("loader", "insert", "导入方式: insert (多行 INSERT), load-data (LOAD DATA LOCAL INFILE 流式导入，服务端未开启 local_infile 时自动回退 insert)")
+
+	// --- 自适应批大小 ---
+	adaptiveBatch = flag.Bool("adaptive-batch", false, "根据每批写入耗时、锁等待和超时自动调整每张表的批大小")
+	targetLatency = flag.Duration("target-latency", 2*time.Second, "自适应模式下每批的目标写入耗时")
+
+	// --- 大字段分块导入 ---
+	lobStream    = flag.Bool("lob-stream", false, "含 CLOB/BLOB 的表逐行导入，大字段按块回源读取并分段追加，内存占用与字段大小无关 (表需有主键)")
+	lobChunkSize = flag.Int64("lob-chunk-size", 1<<20, "分块导入时每次从达梦读取大字段的字节数 (BLOB) 或字符数 (CLOB)")
+
+	// --- 大字段外存 ---
+	offloadDir  = flag.String("offload-dir", "", "外存目录，表配置 offload 中的列写入 <目录>/objects，MySQL 中只保存相对路径和大小")
+	offloadGzip = flag.Bool("offload-gzip", false, "外存文件使用 gzip 压缩")
+
+	// --- 内存预算 ---
+	memoryBudget = flag.Int64("memory-budget", 0, "所有表、所有写入协程在途批次的内存预算 (MB)，用尽时读取端阻塞等待，0 表示不限制")
+
+	// --- 限速 ---
+	readRowsPerSec  = flag.Float64("read-rows-per-sec", 0, "所有表读取达梦的总行数上限 (行/秒)，0 表示不限制")
+	readMBPerSec    = flag.Float64("read-mb-per-sec", 0, "所有表读取达梦的总数据量上限 (MB/秒)，0 表示不限制")
+	writeRowsPerSec = flag.Float64("write-rows-per-sec", 0, "所有表写入 MySQL 的总行数上限 (行/秒)，0 表示不限制")
+	writeMBPerSec   = flag.Float64("write-mb-per-sec", 0, "所有表写入 MySQL 的总数据量上限 (MB/秒)，0 表示不限制")
+	rateLimitFile   = flag.String("rate-limit-file", "", "限速文件 (JSON)，可设置全局和表级限速，运行中修改后自动生效")
+	rateLimitPoll   = flag.Duration("rate-limit-poll", 5*time.Second, "检查限速文件是否修改的间隔")
+
+	// --- 目标库负载保护 ---
+	replicaDSNs       = flag.String("replica-dsn", "", "从库 DSN，多个用分号分隔，如 user:pass@tcp(10.0.0.2:3306)/")
+	replicaLagSource  = flag.String("replica-lag-source", "status", "复制延迟的读取方式: status (SHOW REPLICA STATUS), performance_schema (MySQL 8.0)")
+	maxReplicaLag     = flag.Duration("max-replica-lag", 0, "任一从库复制延迟超过该值时暂停写入，超过一半时减速，0 表示不检查")
+	maxThreadsRunning = flag.Int64("max-threads-running", 0, "主库 Threads_running 超过该值时暂停写入，超过一半时减速，0 表示不检查")
+	guardInterval     = flag.Duration("guard-interval", 5*time.Second, "检查复制延迟和 Threads_running 的间隔")
+
+	// --- 时间窗口 ---
+	scheduleSpec = flag.String("schedule", "", "允许迁移的时间窗口，如 \"mon-fri 22:00-06:00; sat,sun 00:00-24:00\"，窗口外完成手头的批次后暂停，为空表示不限制")
+	scheduleTZ   = flag.String("schedule-tz", "", "时间窗口使用的时区，如 Asia/Shanghai，默认使用本机时区")
+
+	// --- 行级错误隔离 ---
+	deadLetterDir = flag.String("dead-letter-dir", "", "死信目录，设置后写入失败的批次会二分定位坏行，坏行写入 <目录>/<表名>.jsonl")
+	errorBudget   = flag.Int("error-budget", 100, "每张表允许写入死信的最大坏行数，超过后该表失败")
+
+	// --- 读写重试 ---
+	retryAttempts  = flag.Int("retry-attempts", 5, "每批写入最多执行的次数 (含第一次)，1 表示不重试")
+	retryBaseDelay = flag.Duration("retry-base-delay", 500*time.Millisecond, "第一次重试前的等待时间，之后每次翻倍并加入随机抖动")
+	retryMaxDelay  = flag.Duration("retry-max-delay", 30*time.Second, "两次重试之间的最长等待时间")
+	retryOn        = flag.String("retry-on", "connection,deadlock,lock_wait", "可以重试的错误类别，逗号分隔: connection, deadlock, lock_wait, timeout")
+	readRetries    = flag.Int("read-retries", 3, "读取达梦中途出错 (连接断开、游标超时等) 时连续重新查询的最多次数，从最后读出的主键之后继续，0 表示不重试；需要整数主键")
+	readRetryDelay = flag.Duration("read-retry-delay", 5*time.Second, "读取中途出错后第一次重新查询前的等待时间，之后每次翻倍，最长 1 分钟")
+
+	// --- 目标表处理 ---
+	onExists   = flag.String("on-exists", "drop", "目标表已存在时的处理策略: drop, truncate, append, skip, fail")
+	backup     = flag.Bool("backup", false, "drop 策略下不删除旧表，而是重命名为 <表名>__bak_<运行ID> 作为备份")
+	backupKeep = flag.Int("backup-keep", 1, "迁移成功后每张表保留的最近备份份数，0 表示立即删除本次备份")
+	runID      = flag.String("run-id", "", "本次运行 ID，用于命名备份表，默认使用启动时间 (yyyyMMddHHmmss)")
+	shadow     = flag.Bool("shadow", false, "先导入影子表 <表名>__new，完成后通过 RENAME TABLE 原子切换")
+	verifyRows = flag.Bool("verify-count", false, "影子表切换前校验其行数与达梦源表一致")
+
+	// --- 表内分片并发 ---
+	chunkCount   = flag.Int("chunks", 0, "大表按主键或 ROWID 区间切分的分片数，0 或 1 表示不分片")
+	chunkMinRows = flag.Int64("chunk-min-rows", 1000000, "行数达到该值的表才分片")
+	chunkWorkers = flag.Int("chunk-workers", 4, "单表内并发导入的分片数")
+	chunkBy      = flag.String("chunk-by", "range", "分片方式: range (按键值 MIN/MAX 等宽切分), quantile (按行数等分，需全表排序一次)")
+
+	// --- 断点续传 ---
+	resume    = flag.Bool("resume", false, "从状态文件继续上次未完成的运行: 跳过已完成的表，未完成的表从最后提交的主键继续")
+	stateFile = flag.String("state-file", "./migration_state.json", "检查点状态文件路径，每次运行都会记录各表和各分片的进度")
+
+	// --- 增量同步 ---
+	incremental      = flag.Bool("incremental", false, "增量同步: 配置了 watermark 水位列的表只读取上次水位之后的行，并以 upsert 方式写入")
+	watermarkOverlap = flag.String("watermark-overlap", "", "增量同步时水位回退的重叠区间，时间列为时长 (如 5m)，整数列为整数；为空时时间列回退 5m，整数列不回退")
+
+	// --- 一致性快照 ---
+	snapshot = flag.Bool("snapshot", false, "运行开始时记录达梦 LSN，所有表通过闪回查询读取该时刻的数据 (需开启 ENABLE_FLASHBACK)")
 
 	// --- 配置文件 ---
 	tablesConfigFile = flag.String("tables-config", "./config/tables.json", "表配置文件路径")
 )
 
+// memBudget 所有表共享的在途数据内存预算，未开启时为 nil
+var memBudget *database.MemoryBudget
+
+// loadGuard 目标库负载保护，未开启时为 nil
+var loadGuard *database.LoadGuard
+
+// schedule 允许迁移的时间窗口，未设置时为 nil
+var schedule *database.Schedule
+
+// retryPolicy 写入失败时的重试策略
+var retryPolicy *database.RetryPolicy
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法:\n")
+	fmt.Fprintf(out, "  %s [参数]                  执行迁移\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] rollback -run ID  用指定运行的备份表恢复原表\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] cdc install       创建变更日志表并安装变更捕获触发器\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] cdc uninstall     删除变更捕获触发器 (-drop-log 同时删除变更日志表)\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] cdc run           持续把变更应用到 MySQL，Ctrl+C 退出\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] offload verify    按清单校验外存文件\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [参数] offload restore   把外存文件的内容写回 MySQL\n\n", os.Args[0])
+	fmt.Fprintf(out, "参数:\n")
+	flag.PrintDefaults()
+}
+
+// checkMySQLFlags 校验 MySQL 必填参数
+func checkMySQLFlags() {
+	if *mysqlUser == "" || *mysqlPass == "" || *mysqlDB == "" {
+		fmt.Println("❌ MySQL参数缺失")
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
 func buildDMDSN() string {
 	dsn := fmt.Sprintf("dm://%s:%s@%s:%d", *dmUser, *dmPass, *dmHost, *dmPort)
 	var params []string
@@ -54,6 +173,20 @@ func buildDMDSN() string {
 	return dsn
 }
 
+// mysqlSessionInit 返回每条新 MySQL 连接需要执行的会话初始化语句
+// bulkLoad 为 true 表示连接只用于向新建或清空的表 INSERT 全量数据，只有这时才允许关闭唯一索引检查
+func mysqlSessionInit(bulkLoad bool) []string {
+	return database.SessionSettings{
+		DisableForeignKeyChecks: !*fkChecks,
+		DisableUniqueChecks:     bulkLoad && !*uniqueChecks,
+		SQLMode:                 *sqlMode,
+		TimeZone:                *timeZone,
+		DisableBinlog:           *disableBinlog,
+		LockWaitTimeout:         *lockWaitTimeout,
+		Extra:                   strings.Split(*sessionInit, ";"),
+	}.Statements()
+}
+
 func buildMySQLDSN() string {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", *mysqlUser, *mysqlPass, *mysqlHost, *mysqlPort, *mysqlDB)
 
@@ -83,23 +216,58 @@ func buildMySQLDSN() string {
 }
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
+	// 子命令
+	if flag.NArg() > 0 {
+		log.SetFlags(log.LstdFlags | log.Lshortfile)
+		switch flag.Arg(0) {
+		case "rollback":
+			runRollback(flag.Args()[1:])
+		case "cdc":
+			runCDC(flag.Args()[1:])
+		case "offload":
+			runOffload(flag.Args()[1:])
+		default:
+			fmt.Printf("❌ 未知命令: %s\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 校验
 	if *dmUser == "" || *dmPass == "" || *dmSchema == "" {
 		fmt.Println("❌ 达梦参数缺失")
 		flag.Usage()
 		os.Exit(1)
 	}
-	if *mysqlUser == "" || *mysqlPass == "" || *mysqlDB == "" {
-		fmt.Println("❌ MySQL参数缺失")
-		flag.Usage()
-		os.Exit(1)
-	}
+	checkMySQLFlags()
 
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("🚀 开始数据库迁移...")
 
+	// 打开检查点状态文件，续传时沿用上次的运行 ID，备份表名才能对应上
+	var err error
+	store, err = checkpoint.Open(*stateFile, *resume)
+	if err != nil {
+		log.Fatalf("打开状态文件失败: %v", err)
+	}
+	if *runID == "" && *resume {
+		*runID = store.RunID()
+	}
+	if *runID == "" {
+		*runID = time.Now().Format("20060102150405")
+	}
+	if err := store.SetRunID(*runID); err != nil {
+		log.Fatalf("写入状态文件失败: %v", err)
+	}
+	if *resume {
+		log.Printf("♻️  断点续传模式，状态文件: %s", store.Path())
+	}
+	log.Printf("🆔 本次运行 ID: %s", *runID)
+
 	// 加载表配置
 	tablesConfig, err := config.LoadTablesConfig(*tablesConfigFile)
 	if err != nil {
@@ -108,6 +276,81 @@ func main() {
 
 	tables := tablesConfig.Tables
 
+	if *chunkBy != "range" && *chunkBy != "quantile" {
+		log.Fatalf("未知的分片方式 %q (可选: range, quantile)", *chunkBy)
+	}
+	if *lobChunkSize <= 0 {
+		log.Fatalf("-lob-chunk-size 必须大于 0")
+	}
+	if *memoryBudget < 0 {
+		log.Fatalf("-memory-budget 不能为负数")
+	}
+	memBudget = database.NewMemoryBudget(*memoryBudget << 20)
+	if memBudget != nil {
+		log.Printf("💾 在途数据内存预算 %d MB", *memoryBudget)
+	}
+	if *readRowsPerSec < 0 || *readMBPerSec < 0 || *writeRowsPerSec < 0 || *writeMBPerSec < 0 {
+		log.Fatalf("限速参数不能为负数")
+	}
+	if *rateLimitPoll <= 0 {
+		log.Fatalf("-rate-limit-poll 必须大于 0")
+	}
+	initRateLimits(*rateLimitFile, *rateLimitPoll)
+	lagSource, err := database.ParseLagSource(*replicaLagSource)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *guardInterval <= 0 {
+		log.Fatalf("-guard-interval 必须大于 0")
+	}
+	loc := time.Local
+	if *scheduleTZ != "" {
+		if loc, err = time.LoadLocation(*scheduleTZ); err != nil {
+			log.Fatalf("未知的时区 %q: %v", *scheduleTZ, err)
+		}
+	}
+	if schedule, err = database.ParseSchedule(*scheduleSpec, loc); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if schedule != nil {
+		log.Printf("⏰ 迁移时间窗口: %s (%s)", *scheduleSpec, loc)
+	}
+	if *retryAttempts < 1 {
+		log.Fatalf("-retry-attempts 必须大于 0")
+	}
+	if *retryBaseDelay < 0 || *retryMaxDelay < 0 {
+		log.Fatalf("重试等待时间不能为负数")
+	}
+	if *readRetries < 0 || *readRetryDelay < 0 {
+		log.Fatalf("-read-retries 和 -read-retry-delay 不能为负数")
+	}
+	retryClasses, err := database.ParseErrorClasses(*retryOn)
+	if err != nil {
+		log.Fatalf("-retry-on: %v", err)
+	}
+	retryPolicy = &database.RetryPolicy{
+		MaxAttempts: *retryAttempts,
+		BaseDelay:   *retryBaseDelay,
+		MaxDelay:    *retryMaxDelay,
+		Retryable:   retryClasses,
+	}
+
+	// 提前校验每张表的 on-exists 策略和写入方式，避免迁移到一半才发现配置错误
+	for _, t := range tables {
+		if _, err := tableOnExists(t); err != nil {
+			log.Fatalf("表 %s 配置错误: %v", t.Name, err)
+		}
+		if _, err := tableWriteMode(t); err != nil {
+			log.Fatalf("表 %s 配置错误: %v", t.Name, err)
+		}
+		if _, err := tableLoader(t); err != nil {
+			log.Fatalf("表 %s 配置错误: %v", t.Name, err)
+		}
+		if len(t.Offload) > 0 && *offloadDir == "" {
+			log.Fatalf("表 %s 配置了外存列，需要指定 -offload-dir", t.Name)
+		}
+	}
+
 	// 初始化
 	startTime := time.Now()
 	log.Println("🔗 正在连接到达梦数据库...")
@@ -117,30 +360,71 @@ func main() {
 	}
 	defer dmConn.Close()
 	log.Println("✅ 达梦数据库连接成功")
+	if *incremental {
+		// 水位列的类型要从源表结构中得到，连接达梦后立即校验重叠区间，避免同步到一半才发现配置错误
+		if err := checkWatermarkOverlaps(dmConn, tables); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	if anyChunked(tables) {
+		// 每个 Worker 同时读取多个分片，默认的连接数不够用
+		if n := *workerNum * *chunkWorkers; n > 10 {
+			dmConn.SetMaxOpenConns(n)
+		}
+	}
+
+	if *snapshot {
+		startSnapshot(dmConn)
+	}
+	if anyLOBStream(tables) && dmConn.Snapshot() == 0 {
+		// 各块读自同一个定位器，但没有快照点时达梦不保证读取期间源行不被修改
+		log.Println("⚠️  分块导入大字段未开启 -snapshot，读取某行大字段期间源行被并发修改时可能读到不一致的值，并在长度核对时报错；建议源表停写或开启 -snapshot")
+	}
 
 	log.Println("🔗 正在连接到MySQL数据库...")
 	// 传入版本号到 Connector
-	mysqlConn, err := database.NewMySQLConnector(buildMySQLDSN(), *mysqlVer)
+	bulkLoad := uniqueChecksSafe(tables)
+	if !*uniqueChecks && !bulkLoad {
+		log.Println("⚠️  存在增量同步、非 insert 写入方式或 append 策略的表，唯一索引检查仍保持开启，-unique-checks=false 不生效")
+	}
+	mysqlConn, err := database.NewMySQLConnector(buildMySQLDSN(), *mysqlVer, mysqlSessionInit(bulkLoad)...)
 	if err != nil {
 		log.Fatalf("MySQL连接失败: %v", err)
 	}
 	defer mysqlConn.Close()
 	log.Println("✅ MySQL数据库连接成功")
+	for _, stmt := range mysqlSessionInit(bulkLoad) {
+		log.Printf("⚙️  会话初始化: %s", stmt)
+	}
+	// 每个 Worker 的每个分片都会占用 writers 条写入连接，另留少量连接给建表等操作
+	if n := mysqlConnsNeeded(tables); n > 20 {
+		mysqlConn.SetMaxOpenConns(n)
+	}
 
-	// 准备
-	log.Println("⚙️  正在禁用约束检查...")
-	mysqlConn.DisableConstraints()
-	log.Println("✅ 约束检查已禁用")
+	loadGuard, err = database.NewLoadGuard(mysqlConn, database.LoadGuardOptions{
+		ReplicaDSNs:       splitDSNs(*replicaDSNs),
+		LagSource:         lagSource,
+		MaxReplicaLag:     *maxReplicaLag,
+		MaxThreadsRunning: *maxThreadsRunning,
+		Interval:          *guardInterval,
+	})
+	if err != nil {
+		log.Fatalf("启动目标库负载保护失败: %v", err)
+	}
+	defer loadGuard.Close()
+	if loadGuard != nil {
+		log.Printf("🛡️  目标库负载保护已开启 (复制延迟阈值 %v, Threads_running 阈值 %d, 检查间隔 %v)",
+			*maxReplicaLag, *maxThreadsRunning, *guardInterval)
+	}
 
 	log.Printf("📋 准备迁移指定的 %d 张表...", len(tables))
 
 	// 并发
 	var wg sync.WaitGroup
-	jobs := make(chan string, len(tables))
+	jobs := make(chan config.TableConfig, len(tables))
 
-	// 创建一个map来存储每个表的状态
-	tableStatus := make(map[string]string)
-	var statusMutex sync.Mutex
+	// 记录每个表的状态和处理动作
+	report := newMigrationReport(tables)
 
 	// 定期打印状态的goroutine
 	done := make(chan bool)
@@ -150,23 +434,17 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				statusMutex.Lock()
-				completed := 0
-				failed := 0
-				inProgress := 0
-				for _, status := range tableStatus {
-					switch status {
-					case "completed":
-						completed++
-					case "failed":
-						failed++
-					case "in_progress":
-						inProgress++
-					}
+				completed, failed, inProgress, skipped := report.counts()
+				log.Printf("📊 进度统计: 完成 %d, 失败 %d, 进行中 %d, 跳过 %d, 总计 %d",
+					completed, failed, inProgress, skipped, len(tables))
+				if progress := report.chunkProgress(); len(progress) > 0 {
+					log.Printf("🧩 分片进度: %s", strings.Join(progress, ", "))
+				}
+				if memBudget != nil {
+					used, peak, limit, waiting := memBudget.Usage()
+					log.Printf("💾 在途数据: %.1f / %.1f MB (峰值 %.1f MB, 等待 %d)",
+						float64(used)/(1<<20), float64(limit)/(1<<20), float64(peak)/(1<<20), waiting)
 				}
-				statusMutex.Unlock()
-				log.Printf("📊 进度统计: 完成 %d, 失败 %d, 进行中 %d, 总计 %d",
-					completed, failed, inProgress, len(tables))
 			case <-done:
 				return
 			}
@@ -177,10 +455,12 @@ func main() {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			for tableName := range jobs {
-				// 为每个表创建带超时的上下文
-				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-				migrateOneTableWithContext(ctx, id, dmConn, mysqlConn, tableName, tableStatus, &statusMutex)
+			for table := range jobs {
+				// 窗口外不开始新表
+				schedule.Wait(context.Background())
+				// 为每个表创建带超时的上下文，设置了时间窗口时单表可能跨越多个窗口，窗口外暂停的时间不计入超时
+				ctx, cancel := schedule.WithActiveTimeout(context.Background(), 30*time.Minute)
+				migrateOneTableWithContext(ctx, id, dmConn, mysqlConn, table, report)
 				cancel()
 			}
 		}(w)
@@ -193,26 +473,138 @@ func main() {
 	wg.Wait()
 	close(done)
 
-	mysqlConn.EnableConstraints()
+	if err := store.Flush(); err != nil {
+		log.Printf("⚠️  保存状态文件失败: %v", err)
+	}
 	duration := time.Since(startTime)
+	report.printSummary()
 	log.Printf("✅ 迁移完成，耗时: %v", duration)
+	if *backup && *backupKeep > 0 {
+		log.Printf("💡 如需恢复迁移前的数据，可执行: %s [MySQL参数] rollback -run %s", os.Args[0], *runID)
+	}
+}
+
+// splitDSNs 拆分分号分隔的 DSN 列表
+func splitDSNs(s string) []string {
+	var dsns []string
+	for _, dsn := range strings.Split(s, ";") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
+
+// tableShadow 返回表是否使用影子表导入，表级配置优先于 -shadow 参数
+func tableShadow(table config.TableConfig) bool {
+	if table.Shadow != nil {
+		return *table.Shadow
+	}
+	return *shadow
+}
+
+// tableWriteMode 返回表的写入方式，表级配置优先于 -write-mode 参数
+func tableWriteMode(table config.TableConfig) (database.WriteMode, error) {
+	if table.WriteMode != "" {
+		return database.ParseWriteMode(table.WriteMode)
+	}
+	return database.ParseWriteMode(*writeMode)
+}
+
+// tableLoader 返回表的导入方式，表级配置优先于 -loader 参数
+func tableLoader(table config.TableConfig) (database.Loader, error) {
+	if table.Loader != "" {
+		return database.ParseLoader(table.Loader)
+	}
+	return database.ParseLoader(*loader)
+}
+
+// tableAdaptiveBatch 返回表是否自适应调整批大小，表级配置优先于 -adaptive-batch 参数
+func tableAdaptiveBatch(table config.TableConfig) bool {
+	if table.AdaptiveBatch != nil {
+		return *table.AdaptiveBatch
+	}
+	return *adaptiveBatch
+}
+
+// tableLOBStream 返回表是否分块流式导入大字段，表级配置优先于 -lob-stream 参数
+func tableLOBStream(table config.TableConfig) bool {
+	if table.LOBStream != nil {
+		return *table.LOBStream
+	}
+	return *lobStream
+}
+
+// anyLOBStream 判断是否有表分块流式导入大字段
+func anyLOBStream(tables []config.TableConfig) bool {
+	for _, t := range tables {
+		if tableLOBStream(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableWriters 返回表的写入协程数，表级配置优先于 -writers 参数
+func tableWriters(table config.TableConfig) int {
+	if table.Writers != nil {
+		return *table.Writers
+	}
+	return *writerNum
+}
+
+// tableErrorBudget 返回表的错误预算，表级配置优先于 -error-budget 参数
+func tableErrorBudget(table config.TableConfig) int {
+	if table.ErrorBudget != nil {
+		return *table.ErrorBudget
+	}
+	return *errorBudget
 }
 
-func migrateOneTableWithContext(ctx context.Context, workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName string, tableStatus map[string]string, statusMutex *sync.Mutex) {
+// uniqueChecksSafe 判断能否关闭唯一索引检查
+// 关闭后 InnoDB 可能不再校验唯一二级索引，ignore/upsert/replace 和向已有数据的表追加都依赖唯一索引去重，
+// 所以只有全部表都以 insert 方式写入新建或清空的表时才允许关闭
+func uniqueChecksSafe(tables []config.TableConfig) bool {
+	if *incremental {
+		return false
+	}
+	for _, table := range tables {
+		mode, err := tableWriteMode(table)
+		if err != nil || mode != database.WriteModeInsert {
+			return false
+		}
+		policy, err := tableOnExists(table)
+		if err != nil || (policy != database.OnExistsDrop && policy != database.OnExistsTruncate) {
+			return false
+		}
+	}
+	return true
+}
+
+// tableOnExists 返回表的 on-exists 策略，表级配置优先于 -on-exists 参数
+func tableOnExists(table config.TableConfig) (database.OnExistsPolicy, error) {
+	if table.OnExists != "" {
+		return database.ParseOnExistsPolicy(table.OnExists)
+	}
+	return database.ParseOnExistsPolicy(*onExists)
+}
+
+func migrateOneTableWithContext(ctx context.Context, workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, table config.TableConfig, report *migrationReport) {
+	tableName := table.Name
+
 	// 使用select检查上下文是否已取消
 	select {
 	case <-ctx.Done():
 		log.Printf("[Worker %d] ⚠️  表 %s 处理超时或被取消", workerID, tableName)
-		statusMutex.Lock()
-		tableStatus[tableName] = "failed"
-		statusMutex.Unlock()
+		report.update(tableName, func(res *tableResult) {
+			res.Status = statusFailed
+			res.Err = context.Cause(ctx)
+		})
 		return
 	default:
 	}
 
-	statusMutex.Lock()
-	tableStatus[tableName] = "in_progress"
-	statusMutex.Unlock()
+	report.setStatus(tableName, statusInProgress)
 
 	startTime := time.Now()
 	log.Printf("[Worker %d] 🔧 开始处理表 %s", workerID, tableName)
@@ -220,28 +612,142 @@ func migrateOneTableWithContext(ctx context.Context, workerID int, dm *database.
 	// 在单独的goroutine中执行实际工作，并监听上下文取消信号
 	done := make(chan error, 1)
 	go func() {
-		done <- migrateOneTableInternal(workerID, dm, mysql, tableName, tableStatus, statusMutex, startTime)
+		done <- migrateOneTableInternal(ctx, workerID, dm, mysql, table, report, startTime)
 	}()
 
 	select {
 	case <-ctx.Done():
-		log.Printf("[Worker %d] ⚠️  表 %s 处理超时", workerID, tableName)
-		statusMutex.Lock()
-		tableStatus[tableName] = "failed"
-		statusMutex.Unlock()
+		// 读取端和写入端收到取消后写完手头的批次退出，等它们结束后再报告失败，
+		// 之后不会再有批次、检查点或状态写入，-resume 不会与仍在运行的导入竞争
+		log.Printf("[Worker %d] ⚠️  表 %s 处理超时，等待正在写入的批次完成后停止", workerID, tableName)
+		if err := <-done; err != nil {
+			report.update(tableName, func(res *tableResult) {
+				res.Status = statusFailed
+				res.Err = context.Cause(ctx)
+			})
+		}
 	case err := <-done:
 		if err != nil {
 			log.Printf("[Worker %d] ❌ 表 %s 处理出错: %v", workerID, tableName, err)
-			statusMutex.Lock()
-			tableStatus[tableName] = "failed"
-			statusMutex.Unlock()
+			report.update(tableName, func(res *tableResult) {
+				res.Status = statusFailed
+				res.Err = err
+			})
 		}
 	}
 }
 
-func migrateOneTableInternal(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName string, tableStatus map[string]string, statusMutex *sync.Mutex, startTime time.Time) error {
-	// 逻辑同前，省略以节省篇幅...
-	// 这里直接调用 mysql.CreateTable 和 mysql.BatchInsertData 即可
+func migrateOneTableInternal(ctx context.Context, workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, table config.TableConfig, report *migrationReport, startTime time.Time) (err error) {
+	tableName := table.Name
+	policy, err := tableOnExists(table)
+	if err != nil {
+		return err
+	}
+
+	if *incremental && table.Watermark != "" {
+		return migrateIncremental(workerID, dm, mysql, table, report, startTime)
+	}
+
+	// 断点续传: 跳过上次已完成的表；未完成且能按主键续传的表沿用上次的目标表，不再重建
+	ckpt := &tableCheckpoint{name: tableName}
+	var resumed *checkpoint.TableState
+	var priorBackup string
+	if *resume {
+		if st, found := store.Table(tableName); found {
+			if st.Status == checkpoint.StatusCompleted {
+				log.Printf("[Worker %d] ⏭️  表 %s 已在上次运行中完成，跳过", workerID, tableName)
+				report.update(tableName, func(res *tableResult) { res.Action = "done" })
+				report.setStatus(tableName, statusSkipped)
+				return nil
+			}
+			priorBackup = st.Backup
+			if st.KeyColumn != "" {
+				ok, err := mysql.TableExists(st.Target)
+				if err != nil {
+					return err
+				}
+				if ok {
+					resumed = &st
+				}
+			}
+			if resumed == nil {
+				log.Printf("[Worker %d] ⚠️  表 %s 上次未完成，但无法按主键续传，将重新导入", workerID, tableName)
+			}
+		}
+	}
+
+	exists, err := mysql.TableExists(tableName)
+	if err != nil {
+		log.Printf("[Worker %d] ❌ 检查目标表 %s 是否存在失败: %v", workerID, tableName, err)
+		return err
+	}
+
+	// 目标表不存在时一律新建；已存在时按策略决定动作
+	action := "create"
+	if resumed != nil {
+		action = "resume"
+	} else if exists {
+		action = string(policy)
+		if policy == database.OnExistsDrop && *backup {
+			action = "backup"
+		}
+		log.Printf("[Worker %d] 📌 目标表 %s 已存在，按策略 %s 处理", workerID, tableName, action)
+	}
+	report.update(tableName, func(res *tableResult) { res.Action = action })
+
+	// 影子表导入: 所有数据先写入 <表名>__new，正式表在切换前保持不变
+	useShadow := tableShadow(table)
+	if resumed != nil {
+		useShadow = resumed.Target != tableName
+	} else if useShadow && action == string(database.OnExistsAppend) {
+		log.Printf("[Worker %d] ⚠️  表 %s 使用 append 策略，影子表导入不适用，直接追加", workerID, tableName)
+		useShadow = false
+	}
+	loadTarget := tableName
+	if useShadow {
+		loadTarget = database.ShadowTableName(tableName)
+		report.update(tableName, func(res *tableResult) { res.Shadow = true })
+		defer func() {
+			if err == nil {
+				return
+			}
+			if ckpt.key != "" {
+				log.Printf("[Worker %d] 💾 影子表 %s 已保留，可使用 -resume 继续导入", workerID, loadTarget)
+				return
+			}
+			if derr := mysql.DropTable(loadTarget); derr != nil {
+				log.Printf("[Worker %d] ⚠️  删除影子表 %s 失败: %v", workerID, loadTarget, derr)
+			}
+		}()
+	}
+
+	// 本表迁移失败时，用本次运行的备份恢复原表
+	var backupName string
+	defer func() {
+		if err == nil || backupName == "" {
+			return
+		}
+		log.Printf("[Worker %d] ↩️  表 %s 迁移失败，正在从备份 %s 恢复", workerID, tableName, backupName)
+		if rerr := mysql.RestoreBackup(tableName, backupName); rerr != nil {
+			log.Printf("[Worker %d] ❌ 恢复表 %s 失败: %v (备份表 %s 仍保留)", workerID, tableName, rerr, backupName)
+			return
+		}
+		log.Printf("[Worker %d] ✅ 表 %s 已恢复为迁移前的数据", workerID, tableName)
+		report.update(tableName, func(res *tableResult) { res.Backup = "" })
+		// 导入的数据随恢复一起丢弃，下次需要从头导入
+		ckpt.reset()
+	}()
+
+	switch {
+	case resumed != nil:
+	case exists && policy == database.OnExistsSkip:
+		log.Printf("[Worker %d] ⏭️  跳过表 %s", workerID, tableName)
+		report.setStatus(tableName, statusSkipped)
+		return nil
+	case exists && policy == database.OnExistsFail:
+		return fmt.Errorf("目标表 %s 已存在 (on-exists=fail)", tableName)
+	}
+
 	dmCols, err := dm.GetTableSchema(tableName)
 	if err != nil {
 		log.Printf("[Worker %d] ❌ 获取结构失败 %s: %v", workerID, tableName, err)
@@ -250,7 +756,270 @@ func migrateOneTableInternal(workerID int, dm *database.DMConnector, mysql *data
 
 	log.Printf("[Worker %d] 📋 表 %s 包含 %d 个字段", workerID, tableName, len(dmCols))
 
-	// 将 DMColumn 转换为 MySQLColumn
+	ckpt.key = database.ResumeKey(dmCols)
+	if resumed != nil && resumed.KeyColumn != ckpt.key {
+		return fmt.Errorf("表 %s 的主键已由 %s 变为 %s，无法续传，请去掉 -resume 重新导入", tableName, resumed.KeyColumn, ckpt.key)
+	}
+	if ckpt.key == "" && *readRetries > 0 {
+		// 没有可排序的整数键就无法记录读取位置，在开始读取前说明，而不是出错时才发现
+		log.Printf("[Worker %d] ⚠️  表 %s 没有单列整数主键，无法续读 (-read-retries 对该表不生效)，读取中途出错时该表直接失败", workerID, tableName)
+	}
+
+	mysqlCols, err := tableOffload(table, toMySQLColumns(dmCols))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resumed != nil:
+		log.Printf("[Worker %d] ♻️  表 %s 从检查点继续导入 %s (已提交 %d 行)", workerID, tableName, loadTarget, resumed.Rows)
+		backupName = resumed.Backup
+		if backupName != "" {
+			report.update(tableName, func(res *tableResult) { res.Backup = backupName })
+		}
+		// append 策略下目标表原有的行无法与本工具写入的行区分，不能按键删除
+		if resumed.Action != string(database.OnExistsAppend) {
+			if err := discardUncommitted(workerID, mysql, *resumed); err != nil {
+				log.Printf("[Worker %d] ❌ 清理表 %s 未确认的数据失败: %v", workerID, loadTarget, err)
+				return err
+			}
+		}
+	case useShadow:
+		// 清理上次失败残留的影子表
+		if err := mysql.DropTable(loadTarget); err != nil {
+			return err
+		}
+		log.Printf("[Worker %d] 🛠️  正在创建影子表 %s", workerID, loadTarget)
+		if action == string(database.OnExistsTruncate) {
+			// truncate 语义保留目标表结构，影子表按已有表复制结构
+			if err := mysql.CheckTableCompatible(tableName, mysqlCols); err != nil {
+				log.Printf("[Worker %d] ❌ 目标表 %s 结构不兼容: %v", workerID, tableName, err)
+				return err
+			}
+			err = mysql.CreateTableLike(loadTarget, tableName)
+		} else {
+			err = mysql.CreateTable(loadTarget, mysqlCols)
+		}
+		if err != nil {
+			log.Printf("[Worker %d] ❌ 创建影子表失败 %s: %v", workerID, loadTarget, err)
+			return err
+		}
+	case action == "backup":
+		if priorBackup != "" {
+			// 上次运行已备份原表，当前目标表只是未完成的导入结果，直接重建
+			backupName = priorBackup
+			log.Printf("[Worker %d] 💼 表 %s 沿用上次运行的备份 %s", workerID, tableName, backupName)
+		} else {
+			log.Printf("[Worker %d] 💼 正在备份表 %s", workerID, tableName)
+			backupName, err = mysql.BackupTable(tableName, *runID)
+			if err != nil {
+				log.Printf("[Worker %d] ❌ 备份表失败 %s: %v", workerID, tableName, err)
+				return err
+			}
+			log.Printf("[Worker %d] ✅ 表 %s 已重命名为 %s", workerID, tableName, backupName)
+		}
+		report.update(tableName, func(res *tableResult) { res.Backup = backupName })
+		fallthrough
+	case action == "create", action == string(database.OnExistsDrop):
+		log.Printf("[Worker %d] 🛠️  正在创建表 %s", workerID, tableName)
+		if err := mysql.CreateTable(tableName, mysqlCols); err != nil {
+			log.Printf("[Worker %d] ❌ 建表失败 %s: %v", workerID, tableName, err)
+			return err
+		}
+		log.Printf("[Worker %d] ✅ 表 %s 创建成功", workerID, tableName)
+	case action == string(database.OnExistsTruncate), action == string(database.OnExistsAppend):
+		// 保留已有表结构时，必须确认它能接收源表数据
+		if err := mysql.CheckTableCompatible(tableName, mysqlCols); err != nil {
+			log.Printf("[Worker %d] ❌ 目标表 %s 结构不兼容: %v", workerID, tableName, err)
+			return err
+		}
+		if action == string(database.OnExistsTruncate) {
+			log.Printf("[Worker %d] 🧹 正在清空表 %s", workerID, tableName)
+			if err := mysql.TruncateTable(tableName); err != nil {
+				log.Printf("[Worker %d] ❌ 清空表失败 %s: %v", workerID, tableName, err)
+				return err
+			}
+		}
+	}
+
+	mode, err := tableWriteMode(table)
+	if err != nil {
+		return err
+	}
+	ld, err := tableLoader(table)
+	if err != nil {
+		return err
+	}
+	opts := database.InsertOptions{
+		BatchSize: *batchSize,
+		WriteMode: mode,
+		Writers:   tableWriters(table),
+		Loader:    ld,
+
+		AdaptiveBatch: tableAdaptiveBatch(table),
+		TargetLatency: *targetLatency,
+
+		Memory: memBudget,
+		Guard:  loadGuard,
+
+		Schedule: schedule,
+		Retry:    retryPolicy,
+
+		Context: ctx,
+	}
+	opts.ReadLimit, opts.WriteLimit = tableThrottles(tableName)
+	if *deadLetterDir != "" {
+		opts.DeadLetter = database.NewDeadLetter(*deadLetterDir, tableName, tableErrorBudget(table))
+		defer opts.DeadLetter.Close()
+	}
+	if tableLOBStream(table) {
+		opts.LOB = lobStreamFor(workerID, dm, tableName, mysqlCols)
+	}
+	// 续传和 append 策略下目标表保留已有的行，清单也接着追加
+	opts.Offload, err = offloaderFor(table, resumed != nil || action == string(database.OnExistsAppend))
+	if err != nil {
+		return fmt.Errorf("打开表 %s 的外存清单失败: %v", tableName, err)
+	}
+	defer opts.Offload.Close()
+	if resumed != nil && resumed.Action == string(database.OnExistsAppend) && opts.WriteMode == database.WriteModeInsert {
+		// 检查点之后的行可能已经写入，改用 upsert 覆盖，避免主键冲突
+		log.Printf("[Worker %d] ⚠️  表 %s 使用 append 策略续传，写入方式改为 upsert", workerID, tableName)
+		opts.WriteMode = database.WriteModeUpsert
+	}
+
+	// 大表按主键或 ROWID 区间分片并发导入，续传时沿用上次的分片边界
+	var chunks []database.ChunkRange
+	if resumed != nil {
+		chunks = chunkRanges(*resumed)
+	} else {
+		chunks, err = planChunks(workerID, dm, table, dmCols)
+		if err != nil {
+			log.Printf("[Worker %d] ❌ 表 %s 分片失败: %v", workerID, tableName, err)
+			return err
+		}
+		ckpt.start(loadTarget, backupName, action, chunks)
+	}
+	var stats database.InsertStats
+	if len(chunks) > 0 {
+		stats, err = loadChunks(workerID, dm, mysql, tableName, loadTarget, mysqlCols, chunks, opts, report, ckpt)
+	} else {
+		var after *int64
+		if resumed != nil {
+			after = resumed.LastKey
+		}
+		stats, err = loadTable(workerID, dm, mysql, tableName, loadTarget, mysqlCols, opts, ckpt, after)
+	}
+	report.update(tableName, func(res *tableResult) {
+		res.Stats = stats
+		if stats.Failed > 0 {
+			res.DeadLetter = opts.DeadLetter.Path()
+		}
+	})
+	if err != nil {
+		log.Printf("[Worker %d] ❌ 写数据失败 %s: %v", workerID, tableName, err)
+		return err
+	}
+
+	if useShadow {
+		if err := swapShadowTable(workerID, dm, mysql, tableName, exists, stats, &backupName); err != nil {
+			log.Printf("[Worker %d] ❌ 切换影子表失败 %s: %v", workerID, tableName, err)
+			return err
+		}
+		if backupName != "" {
+			report.update(tableName, func(res *tableResult) { res.Backup = backupName })
+			ckpt.setBackup(backupName)
+		}
+	}
+
+	duration := time.Since(startTime)
+	log.Printf("[Worker %d] ✅ %s 完成 (%d 行, %s, 耗时: %v)",
+		workerID, tableName, stats.Rows, stats.Breakdown(), duration)
+	log.Printf("[Worker %d] ⏱️  表 %s 写入端等待读取 %v, 读取端等待写入 %v, 瓶颈: %s",
+		workerID, tableName, stats.ReadWait.Round(time.Millisecond), stats.WriteWait.Round(time.Millisecond), stats.Bottleneck())
+	if stats.ScheduleWait > 0 {
+		log.Printf("[Worker %d] ⏰ 表 %s 在时间窗口外暂停 %v", workerID, tableName, stats.ScheduleWait.Round(time.Second))
+	}
+	if stats.Retries > 0 {
+		log.Printf("[Worker %d] 🔁 表 %s 写入重试 %d 次", workerID, tableName, stats.Retries)
+	}
+	if stats.Resumes > 0 {
+		log.Printf("[Worker %d] ♻️  表 %s 读取中途出错后重新查询 %d 次", workerID, tableName, stats.Resumes)
+	}
+	if stats.GuardWait > time.Second {
+		log.Printf("[Worker %d] ⏸️  表 %s 因目标库负载过高等待 %v", workerID, tableName, stats.GuardWait.Round(time.Millisecond))
+	}
+	if stats.RateWait > time.Second {
+		log.Printf("[Worker %d] 🚦 表 %s 因限速等待 %v", workerID, tableName, stats.RateWait.Round(time.Millisecond))
+	}
+	if stats.MemWait > time.Second {
+		log.Printf("[Worker %d] 💾 表 %s 读取端等待内存预算 %v", workerID, tableName, stats.MemWait.Round(time.Millisecond))
+	}
+	if stats.BatchMin != stats.BatchMax {
+		log.Printf("[Worker %d] 🎚️  表 %s 批大小在 %d~%d 行之间调整", workerID, tableName, stats.BatchMin, stats.BatchMax)
+	}
+	if stats.Failed > 0 {
+		log.Printf("[Worker %d] ⚠️  表 %s 有 %d 行写入失败，已记录到 %s", workerID, tableName, stats.Failed, opts.DeadLetter.Path())
+	}
+
+	// 快照读取的数据截至快照点，配置了水位列时把快照时的最大值记为水位，后续增量同步从这里继续
+	if table.Watermark != "" && dm.Snapshot() != 0 {
+		if err := saveSnapshotWatermark(dm, table, dmCols); err != nil {
+			log.Printf("[Worker %d] ⚠️  记录表 %s 的快照水位失败: %v", workerID, tableName, err)
+		}
+	}
+
+	report.setStatus(tableName, statusCompleted)
+	ckpt.complete(stats.Rows)
+
+	// 按保留份数清理旧备份
+	if backupName != "" {
+		dropped, err := mysql.PruneBackups(tableName, *backupKeep)
+		if err != nil {
+			log.Printf("[Worker %d] ⚠️  清理表 %s 的备份失败: %v", workerID, tableName, err)
+		}
+		for _, name := range dropped {
+			log.Printf("[Worker %d] 🗑️  已删除备份表 %s", workerID, name)
+			if name == backupName {
+				report.update(tableName, func(res *tableResult) { res.Backup = "" })
+			}
+		}
+	}
+
+	return nil
+}
+
+// startSnapshot 确定本次运行的一致性快照点，续传时沿用状态文件中记录的快照点
+// 未开启闪回时各表仍按各自开始读取的时刻读取
+func startSnapshot(dm *database.DMConnector) {
+	enabled, err := dm.FlashbackEnabled()
+	if err != nil {
+		log.Printf("⚠️  查询闪回配置失败，各表将独立读取: %v", err)
+		return
+	}
+	if !enabled {
+		log.Println("⚠️  达梦未开启闪回 (ENABLE_FLASHBACK)，无法使用一致性快照，各表将独立读取")
+		return
+	}
+
+	if snap, ok := store.Snapshot(); ok && *resume {
+		dm.SetSnapshot(snap.LSN)
+		log.Printf("📸 沿用上次运行的快照点 LSN %d (%s)", snap.LSN, snap.Time)
+		return
+	}
+
+	lsn, at, err := dm.CurrentLSN()
+	if err != nil {
+		log.Fatalf("读取达梦当前 LSN 失败: %v", err)
+	}
+	if err := store.SetSnapshot(checkpoint.Snapshot{LSN: lsn, Time: at.Format(time.RFC3339)}); err != nil {
+		log.Fatalf("写入状态文件失败: %v", err)
+	}
+	dm.SetSnapshot(lsn)
+	log.Printf("📸 一致性快照点 LSN %d (%s)，所有表读取该时刻的数据", lsn, at.Format("2006-01-02 15:04:05"))
+}
+
+// toMySQLColumns 将 DMColumn 转换为 MySQLColumn
+func toMySQLColumns(dmCols []database.DMColumn) []database.MySQLColumn {
 	mysqlCols := make([]database.MySQLColumn, len(dmCols))
 	for i, col := range dmCols {
 		mysqlCols[i] = database.MySQLColumn{
@@ -264,42 +1033,164 @@ func migrateOneTableInternal(workerID int, dm *database.DMConnector, mysql *data
 			IsAutoIncrement: col.IsIdentity,
 		}
 	}
+	return mysqlCols
+}
 
-	log.Printf("[Worker %d] 🛠️  正在创建表 %s", workerID, tableName)
-	if err := mysql.CreateTable(tableName, mysqlCols); err != nil {
-		log.Printf("[Worker %d] ❌ 建表失败 %s: %v", workerID, tableName, err)
-		return err
+// tableOffload 按表配置标记外存列，并在每个外存列后插入保存原始字节数的大小列
+func tableOffload(table config.TableConfig, cols []database.MySQLColumn) ([]database.MySQLColumn, error) {
+	if len(table.Offload) == 0 {
+		return cols, nil
+	}
+	names := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		names[strings.ToUpper(col.Name)] = true
+	}
+	offload := make(map[string]bool, len(table.Offload))
+	for _, name := range table.Offload {
+		if !names[strings.ToUpper(name)] {
+			return nil, fmt.Errorf("表 %s 配置的外存列 %s 不存在", table.Name, name)
+		}
+		offload[strings.ToUpper(name)] = true
+	}
+
+	out := make([]database.MySQLColumn, 0, len(cols)+len(offload))
+	for _, col := range cols {
+		if !offload[strings.ToUpper(col.Name)] {
+			out = append(out, col)
+			continue
+		}
+		if !database.IsLOBType(col.DataType) {
+			return nil, fmt.Errorf("表 %s 的列 %s 类型为 %s，只有大字段列可以外存", table.Name, col.Name, col.DataType)
+		}
+		if col.IsPrimaryKey {
+			return nil, fmt.Errorf("表 %s 的列 %s 是主键，不能外存", table.Name, col.Name)
+		}
+		size := col.Name + database.OffloadSizeSuffix
+		if names[strings.ToUpper(size)] {
+			return nil, fmt.Errorf("表 %s 已有列 %s，无法作为外存列 %s 的大小列", table.Name, size, col.Name)
+		}
+		col.Offload = true
+		out = append(out, col, database.MySQLColumn{Name: size, SizeOf: col.Name, Nullable: col.Nullable})
+	}
+	return out, nil
+}
+
+// offloaderFor 为配置了外存列的表打开外存写入器，没有外存列时返回 nil
+func offloaderFor(table config.TableConfig, appendManifest bool) (*database.Offloader, error) {
+	if len(table.Offload) == 0 {
+		return nil, nil
 	}
-	log.Printf("[Worker %d] ✅ 表 %s 创建成功", workerID, tableName)
+	return database.NewOffloader(*offloadDir, table.Name, *offloadGzip, appendManifest)
+}
 
+// lobStreamFor 为含大字段且有主键的表返回分块导入参数，不满足条件时返回 nil 按普通方式导入
+// 外存列整体读取，不计入分块导入的大字段
+func lobStreamFor(workerID int, dm *database.DMConnector, tableName string, cols []database.MySQLColumn) *database.LOBStream {
+	var hasLOB, hasPK bool
+	for _, col := range cols {
+		hasLOB = hasLOB || (database.IsLOBType(col.DataType) && !col.Offload)
+		hasPK = hasPK || col.IsPrimaryKey
+	}
+	if !hasLOB {
+		return nil
+	}
+	if !hasPK {
+		log.Printf("[Worker %d] ⚠️  表 %s 没有主键，无法分块导入大字段，按普通方式导入", workerID, tableName)
+		return nil
+	}
+	return &database.LOBStream{Source: dm, Table: tableName, ChunkSize: *lobChunkSize}
+}
+
+// loadTable 整表读取并写入目标表
+// 有整数主键时按主键升序读取键值大于 after 的行，并把已提交的主键记录到检查点
+func loadTable(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName, loadTarget string, mysqlCols []database.MySQLColumn, opts database.InsertOptions, ckpt *tableCheckpoint, after *int64) (database.InsertStats, error) {
 	log.Printf("[Worker %d] 📥 正在读取表 %s 数据", workerID, tableName)
-	rows, err := dm.GetTableData(tableName)
+	var rows database.RowSource
+	var resumable *database.ResumableRows
+	var err error
+	if ckpt.key != "" {
+		opts.KeyColumn = ckpt.key
+		opts.OnCommit = ckpt.commitTable
+		resumable, err = dm.GetTableDataResumable(tableName, opts.LOB.ReadColumns(mysqlCols), ckpt.key, after, nil, readRetry())
+		rows = resumable
+	} else {
+		rows, err = dm.GetTableData(tableName, opts.LOB.ReadColumns(mysqlCols))
+	}
 	if err != nil {
 		log.Printf("[Worker %d] ❌ 读数据失败 %s: %v", workerID, tableName, err)
-		return err
+		return database.InsertStats{}, err
 	}
 	defer rows.Close()
 
 	log.Printf("[Worker %d] 💾 正在写入表 %s 数据", workerID, tableName)
-	insertedRows, err := mysql.BatchInsertData(tableName, mysqlCols, rows, *batchSize)
-	if err != nil {
-		log.Printf("[Worker %d] ❌ 写数据失败 %s: %v", workerID, tableName, err)
-		return err
+	stats, err := mysql.BatchInsertData(loadTarget, mysqlCols, rows, opts)
+	if resumable != nil {
+		stats.Resumes = int64(resumable.Resumes())
 	}
+	return stats, err
+}
 
-	duration := time.Since(startTime)
-	log.Printf("[Worker %d] ✅ %s 完成 (%d 行, 耗时: %v)", workerID, tableName, insertedRows, duration)
+// readRetry 读取达梦中途出错后重新查询的参数
+func readRetry() database.ReadRetry {
+	return database.ReadRetry{Attempts: *readRetries, Delay: *readRetryDelay}
+}
 
-	statusMutex.Lock()
-	tableStatus[tableName] = "completed"
-	statusMutex.Unlock()
+// swapShadowTable 校验影子表后将其原子切换为正式表
+// 开启 -backup 时换下的旧表保留为本次运行的备份表，否则直接删除
+// stats 为写入统计，校验行数时扣除写入死信、被忽略或覆盖了其他行的源表行
+func swapShadowTable(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName string, exists bool, stats database.InsertStats, backupName *string) error {
+	shadowName := database.ShadowTableName(tableName)
 
+	if *verifyRows {
+		srcCount, err := dm.CountRows(tableName)
+		if err != nil {
+			return fmt.Errorf("统计源表行数失败: %v", err)
+		}
+		dstCount, err := mysql.CountRows(shadowName)
+		if err != nil {
+			return fmt.Errorf("统计影子表行数失败: %v", err)
+		}
+		unwritten := stats.Unwritten()
+		if srcCount-unwritten != dstCount {
+			return fmt.Errorf("行数校验失败: 源表 %d 行 (其中坏行 %d, 重复键未新增 %d), 影子表 %d 行",
+				srcCount, stats.Failed, unwritten-stats.Failed, dstCount)
+		}
+		if unwritten > 0 {
+			log.Printf("[Worker %d] ✅ 影子表 %s 行数校验通过 (%d 行, 源表 %d 行中 %d 行为坏行或重复键)", workerID, shadowName, dstCount, srcCount, unwritten)
+		} else {
+			log.Printf("[Worker %d] ✅ 影子表 %s 行数校验通过 (%d 行)", workerID, shadowName, dstCount)
+		}
+	}
+
+	retired := database.RetiredTableName(tableName)
+	if exists && *backup {
+		retired = database.BackupTableName(tableName, *runID)
+	} else if err := mysql.DropTable(retired); err != nil {
+		// 清理上次残留的旧表
+		return err
+	}
+
+	log.Printf("[Worker %d] 🔀 正在切换影子表 %s -> %s", workerID, shadowName, tableName)
+	if err := mysql.SwapShadowTable(tableName, shadowName, retired); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	if *backup {
+		*backupName = retired
+		log.Printf("[Worker %d] 💼 旧表已保留为备份 %s", workerID, retired)
+		return nil
+	}
+	if err := mysql.DropTable(retired); err != nil {
+		log.Printf("[Worker %d] ⚠️  删除旧表 %s 失败: %v", workerID, retired, err)
+	}
 	return nil
 }
 
-func migrateOneTable(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, tableName string, tableStatus map[string]string, statusMutex *sync.Mutex) {
+func migrateOneTable(workerID int, dm *database.DMConnector, mysql *database.MySQLConnector, table config.TableConfig, report *migrationReport) {
 	// 保留此函数以保持向后兼容性，但实际逻辑已转移到带上下文的版本
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-	migrateOneTableWithContext(ctx, workerID, dm, mysql, tableName, tableStatus, statusMutex)
+	migrateOneTableWithContext(ctx, workerID, dm, mysql, table, report)
 	cancel()
 }
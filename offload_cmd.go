@@ -0,0 +1,166 @@
+package main
+
+import (
+	"dm2mysql-migrator/database"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runOffload 执行 offload 子命令
+//
+//	offload verify   按清单校验外存文件的大小和摘要
+//	offload restore  把外存文件的内容写回 MySQL，用于重新导入大字段
+func runOffload(args []string) {
+	fs := flag.NewFlagSet("offload", flag.ExitOnError)
+	dir := fs.String("dir", *offloadDir, "外存目录，默认使用 -offload-dir")
+	table := fs.String("table", "", "只处理指定表，verify 时为空表示全部表，restore 时必填")
+	column := fs.String("column", "", "restore: 只写回指定列，为空表示清单中的全部列")
+	into := fs.String("into", "", "restore: 写入的目标列，为空时写回原列 (需先把原列改为 LONGBLOB)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s [参数] offload verify|restore [子命令参数]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	if *dir == "" {
+		fmt.Println("❌ 缺少 -dir 参数")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	switch action {
+	case "verify":
+		offloadVerify(*dir, *table)
+	case "restore":
+		if *table == "" {
+			fmt.Println("❌ restore 需要指定 -table")
+			fs.Usage()
+			os.Exit(1)
+		}
+		if *into != "" && *column == "" {
+			fmt.Println("❌ 指定 -into 时需要同时指定 -column")
+			fs.Usage()
+			os.Exit(1)
+		}
+		offloadRestore(*dir, *table, *column, *into)
+	default:
+		fmt.Printf("❌ 未知的 offload 操作: %s\n", action)
+		fs.Usage()
+		os.Exit(1)
+	}
+}
+
+// offloadManifests 返回需要处理的清单文件
+func offloadManifests(dir, table string) ([]string, error) {
+	if table != "" {
+		return []string{filepath.Join(dir, "manifest", table+".jsonl")}, nil
+	}
+	return filepath.Glob(filepath.Join(dir, "manifest", "*.jsonl"))
+}
+
+// offloadVerify 校验清单中每个外存文件，有缺失或不一致时以非零状态退出
+func offloadVerify(dir, table string) {
+	manifests, err := offloadManifests(dir, table)
+	if err != nil {
+		log.Fatalf("查找清单失败: %v", err)
+	}
+	if len(manifests) == 0 {
+		log.Printf("⚠️  %s 下没有清单文件", dir)
+		return
+	}
+
+	var ok, missing, mismatch int
+	for _, m := range manifests {
+		// 同一内容可能被多行引用，只校验一次
+		checked := make(map[string]error)
+		err := database.ReadOffloadManifest(m, func(e database.OffloadEntry) error {
+			verr, seen := checked[e.Path]
+			if !seen {
+				verr = database.VerifyOffloadEntry(dir, e)
+				checked[e.Path] = verr
+			}
+			switch {
+			case verr == nil:
+				ok++
+			case os.IsNotExist(verr):
+				missing++
+				log.Printf("❌ %s.%s %v: 文件 %s 不存在", e.Table, e.Column, e.Key, e.Path)
+			default:
+				mismatch++
+				log.Printf("❌ %s.%s %v: %s %v", e.Table, e.Column, e.Key, e.Path, verr)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("读取清单 %s 失败: %v", m, err)
+		}
+	}
+
+	log.Printf("🔍 校验完成: 正常 %d, 缺失 %d, 不一致 %d", ok, missing, mismatch)
+	if missing > 0 || mismatch > 0 {
+		os.Exit(1)
+	}
+}
+
+// offloadRestore 按清单把外存内容写回 MySQL，同一行同一列以清单中最后一条记录为准
+func offloadRestore(dir, table, column, into string) {
+	checkMySQLFlags()
+	mysqlConn, err := database.NewMySQLConnector(buildMySQLDSN(), *mysqlVer, mysqlSessionInit(false)...)
+	if err != nil {
+		log.Fatalf("MySQL连接失败: %v", err)
+	}
+	defer mysqlConn.Close()
+
+	type rowColumn struct{ key, column string }
+	latest := make(map[rowColumn]database.OffloadEntry)
+	var order []rowColumn
+	err = database.ReadOffloadManifest(filepath.Join(dir, "manifest", table+".jsonl"), func(e database.OffloadEntry) error {
+		if column != "" && !strings.EqualFold(e.Column, column) {
+			return nil
+		}
+		rc := rowColumn{key: fmt.Sprint(e.Key), column: e.Column}
+		if _, seen := latest[rc]; !seen {
+			order = append(order, rc)
+		}
+		latest[rc] = e
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("读取清单失败: %v", err)
+	}
+
+	log.Printf("📥 开始写回表 %s 的 %d 个外存值", table, len(order))
+	var restored, notFound int
+	for _, rc := range order {
+		e := latest[rc]
+		data, err := database.ReadOffloadObject(dir, e)
+		if err == nil {
+			err = database.CheckOffloadData(e, data)
+		}
+		if err != nil {
+			log.Fatalf("读取外存文件 %s 失败: %v", e.Path, err)
+		}
+		target := e.Column
+		if into != "" {
+			target = into
+		}
+		n, err := mysqlConn.RestoreOffloaded(table, target, e.Key, data)
+		if err != nil {
+			log.Fatalf("写回 %s.%s %v 失败: %v", table, target, e.Key, err)
+		}
+		if n == 0 {
+			notFound++
+		}
+		restored++
+	}
+	log.Printf("✅ 写回完成: %d 个值, 其中 %d 行在目标表中不存在或内容未变", restored, notFound)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"dm2mysql-migrator/config"
+	"dm2mysql-migrator/database"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// rateLimits 全局和各表的限速，限速文件修改后原地调整速率，正在导入的表立即生效
+type rateLimits struct {
+	read  *database.RateLimit
+	write *database.RateLimit
+
+	mu     sync.Mutex
+	tables map[string][2]*database.RateLimit // 表名 -> 读取、写入限速
+}
+
+// limits 本次运行的限速
+var limits = &rateLimits{
+	read:   database.NewRateLimit(0, 0),
+	write:  database.NewRateLimit(0, 0),
+	tables: make(map[string][2]*database.RateLimit),
+}
+
+// tableThrottles 返回表的读取和写入限速，全局限速与表级限速同时生效
+func tableThrottles(tableName string) (read, write database.Throttle) {
+	limits.mu.Lock()
+	defer limits.mu.Unlock()
+	t, ok := limits.tables[tableName]
+	if !ok {
+		// 限速文件中暂时没有的表也先建好，之后加上表级限速时能立即生效
+		t = [2]*database.RateLimit{database.NewRateLimit(0, 0), database.NewRateLimit(0, 0)}
+		limits.tables[tableName] = t
+	}
+	return database.Throttle{limits.read, t[0]}, database.Throttle{limits.write, t[1]}
+}
+
+// applyRateLimits 按限速文件调整速率，cfg 为空时只使用命令行参数
+func applyRateLimits(cfg *config.RateLimitConfig) {
+	if cfg == nil {
+		cfg = &config.RateLimitConfig{}
+	}
+	set := func(l *database.RateLimit, s *config.RateSpec) {
+		if s == nil {
+			l.Set(0, 0)
+			return
+		}
+		l.Set(s.RowsPerSec, s.MBPerSec*(1<<20))
+	}
+
+	read, write := cfg.Read, cfg.Write
+	if read == nil {
+		read = &config.RateSpec{RowsPerSec: *readRowsPerSec, MBPerSec: *readMBPerSec}
+	}
+	if write == nil {
+		write = &config.RateSpec{RowsPerSec: *writeRowsPerSec, MBPerSec: *writeMBPerSec}
+	}
+	set(limits.read, read)
+	set(limits.write, write)
+
+	limits.mu.Lock()
+	defer limits.mu.Unlock()
+	for name := range cfg.Tables {
+		if _, ok := limits.tables[name]; !ok {
+			limits.tables[name] = [2]*database.RateLimit{database.NewRateLimit(0, 0), database.NewRateLimit(0, 0)}
+		}
+	}
+	// 从文件中删去的表恢复为不限制
+	for name, t := range limits.tables {
+		tc := cfg.Tables[name]
+		set(t[0], tc.Read)
+		set(t[1], tc.Write)
+	}
+}
+
+// describeRate 格式化限速，用于日志
+func describeRate(s *config.RateSpec) string {
+	if s == nil || (s.RowsPerSec <= 0 && s.MBPerSec <= 0) {
+		return "不限"
+	}
+	var out string
+	if s.RowsPerSec > 0 {
+		out = fmt.Sprintf("%.0f 行/秒", s.RowsPerSec)
+	}
+	if s.MBPerSec > 0 {
+		if out != "" {
+			out += ", "
+		}
+		out += fmt.Sprintf("%.1f MB/秒", s.MBPerSec)
+	}
+	return out
+}
+
+// logRateLimits 打印当前生效的限速
+func logRateLimits(cfg *config.RateLimitConfig) {
+	read := &config.RateSpec{RowsPerSec: *readRowsPerSec, MBPerSec: *readMBPerSec}
+	write := &config.RateSpec{RowsPerSec: *writeRowsPerSec, MBPerSec: *writeMBPerSec}
+	if cfg != nil && cfg.Read != nil {
+		read = cfg.Read
+	}
+	if cfg != nil && cfg.Write != nil {
+		write = cfg.Write
+	}
+	log.Printf("🚦 全局限速: 读取 %s, 写入 %s", describeRate(read), describeRate(write))
+	if cfg == nil {
+		return
+	}
+	for name, t := range cfg.Tables {
+		log.Printf("🚦 表 %s 限速: 读取 %s, 写入 %s", name, describeRate(t.Read), describeRate(t.Write))
+	}
+}
+
+// initRateLimits 加载限速，指定了限速文件时在后台定期检查，文件修改后重新加载
+func initRateLimits(path string, interval time.Duration) {
+	if path == "" {
+		applyRateLimits(nil)
+		if *readRowsPerSec > 0 || *readMBPerSec > 0 || *writeRowsPerSec > 0 || *writeMBPerSec > 0 {
+			logRateLimits(nil)
+		}
+		return
+	}
+
+	cfg, err := config.LoadRateLimitConfig(path)
+	if err != nil {
+		log.Fatalf("加载限速文件失败: %v", err)
+	}
+	applyRateLimits(cfg)
+	logRateLimits(cfg)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatalf("读取限速文件失败: %v", err)
+	}
+	modTime := info.ModTime()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			// 文件写到一半或格式有误时保留原有限速，等下次修改
+			cfg, err := config.LoadRateLimitConfig(path)
+			if err != nil {
+				log.Printf("⚠️  重新加载限速文件失败，沿用原有限速: %v", err)
+				continue
+			}
+			applyRateLimits(cfg)
+			log.Printf("🚦 限速文件 %s 已重新加载", path)
+			logRateLimits(cfg)
+		}
+	}()
+}
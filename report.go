@@ -0,0 +1,187 @@
+package main
+
+import (
+	"dm2mysql-migrator/config"
+	"dm2mysql-migrator/database"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 单表迁移状态
+const (
+	statusPending    = "pending"
+	statusInProgress = "in_progress"
+	statusCompleted  = "completed"
+	statusFailed     = "failed"
+	statusSkipped    = "skipped"
+)
+
+// tableResult 记录单表的迁移结果
+type tableResult struct {
+	Status string
+	Action string // 对目标表执行的动作: create / drop / backup / truncate / append / skip / fail / resume / done
+	Backup string // 本次运行保留的备份表名
+	Shadow bool   // 是否通过影子表切换完成
+	Stats  database.InsertStats
+	Err    error
+
+	DeadLetter string // 坏行所在的死信文件
+
+	Chunks     int // 分片总数，0 表示未分片
+	ChunksDone int // 已完成的分片数
+
+}
+
+// migrationReport 汇总所有表的迁移状态，供定期进度统计和最终汇总使用
+type migrationReport struct {
+	mu      sync.Mutex
+	order   []string // 保持配置文件中的表顺序
+	results map[string]*tableResult
+}
+
+func newMigrationReport(tables []config.TableConfig) *migrationReport {
+	r := &migrationReport{results: make(map[string]*tableResult)}
+	for _, t := range tables {
+		r.order = append(r.order, t.Name)
+		r.results[t.Name] = &tableResult{Status: statusPending}
+	}
+	return r
+}
+
+// update 在锁保护下修改某张表的结果
+func (r *migrationReport) update(tableName string, fn func(res *tableResult)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.results[tableName]
+	if !ok {
+		res = &tableResult{Status: statusPending}
+		r.results[tableName] = res
+		r.order = append(r.order, tableName)
+	}
+	fn(res)
+}
+
+// setStatus 更新表状态
+func (r *migrationReport) setStatus(tableName, status string) {
+	r.update(tableName, func(res *tableResult) { res.Status = status })
+}
+
+// counts 统计各状态的表数量
+func (r *migrationReport) counts() (completed, failed, inProgress, skipped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range r.results {
+		switch res.Status {
+		case statusCompleted:
+			completed++
+		case statusFailed:
+			failed++
+		case statusInProgress:
+			inProgress++
+		case statusSkipped:
+			skipped++
+		}
+	}
+	return
+}
+
+// chunkProgress 返回正在分片导入的表及其分片进度
+func (r *migrationReport) chunkProgress() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var progress []string
+	for _, name := range r.order {
+		res := r.results[name]
+		if res.Status == statusInProgress && res.Chunks > 0 {
+			progress = append(progress, fmt.Sprintf("%s %d/%d", name, res.ChunksDone, res.Chunks))
+		}
+	}
+	return progress
+}
+
+// printSummary 输出每张表的处理动作与结果
+func (r *migrationReport) printSummary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Println("📑 迁移汇总:")
+	var completed, failed, skipped int
+	var total database.InsertStats
+	for _, name := range r.order {
+		res := r.results[name]
+		action := res.Action
+		if action == "" {
+			action = "-"
+		}
+		switch res.Status {
+		case statusCompleted:
+			completed++
+			total.Rows += res.Stats.Rows
+			total.Inserted += res.Stats.Inserted
+			total.Updated += res.Stats.Updated
+			total.InsertedOrSame += res.Stats.InsertedOrSame
+			total.Ignored += res.Stats.Ignored
+			total.Failed += res.Stats.Failed
+			total.Retries += res.Stats.Retries
+			total.Resumes += res.Stats.Resumes
+			total.ReadWait += res.Stats.ReadWait
+			total.WriteWait += res.Stats.WriteWait
+			total.MemWait += res.Stats.MemWait
+			total.RateWait += res.Stats.RateWait
+			total.GuardWait += res.Stats.GuardWait
+			total.ScheduleWait += res.Stats.ScheduleWait
+			if res.Shadow {
+				action += "(影子表)"
+			}
+			log.Printf("   ✅ %-30s 动作: %-8s 行数: %d (%s)",
+				name, action, res.Stats.Rows, res.Stats.Breakdown())
+			if res.Backup != "" {
+				log.Printf("      💼 备份表: %s", res.Backup)
+			}
+			if res.Stats.ScheduleWait > 0 {
+				log.Printf("      ⏰ 时间窗口外暂停: %v", res.Stats.ScheduleWait.Round(time.Second))
+			}
+			if res.Stats.BatchMin != res.Stats.BatchMax {
+				log.Printf("      🎚️  批大小: %d~%d 行", res.Stats.BatchMin, res.Stats.BatchMax)
+			}
+			if res.Stats.Failed > 0 {
+				log.Printf("      ⚠️  坏行 %d 行: %s", res.Stats.Failed, res.DeadLetter)
+			}
+		case statusSkipped:
+			skipped++
+			log.Printf("   ⏭️  %-30s 动作: %-8s", name, action)
+		default:
+			failed++
+			log.Printf("   ❌ %-30s 动作: %-8s 错误: %v", name, action, res.Err)
+			if res.DeadLetter != "" {
+				log.Printf("      ⚠️  坏行 %d 行: %s", res.Stats.Failed, res.DeadLetter)
+			}
+		}
+	}
+	log.Printf("📑 共 %d 张表: 成功 %d, 失败 %d, 跳过 %d, 写入 %d 行 (%s, 坏行 %d)",
+		len(r.order), completed, failed, skipped, total.Rows, total.Breakdown(), total.Failed)
+	log.Printf("⏱️  累计写入端等待读取 %v, 读取端等待写入 %v, 整体瓶颈: %s",
+		total.ReadWait.Round(time.Second), total.WriteWait.Round(time.Second), total.Bottleneck())
+	if n, d := schedule.Pauses(); n > 0 {
+		log.Printf("⏰ 时间窗口外暂停 %d 次, 累计 %v", n, d.Round(time.Second))
+	}
+	if n, d := loadGuard.Pauses(); n > 0 {
+		log.Printf("⏸️  目标库负载过高暂停写入 %d 次, 累计 %v", n, d.Round(time.Second))
+	}
+	if total.Retries > 0 {
+		log.Printf("🔁 累计写入重试 %d 次", total.Retries)
+	}
+	if total.Resumes > 0 {
+		log.Printf("♻️  累计读取中途出错后重新查询 %d 次", total.Resumes)
+	}
+	if total.RateWait > 0 {
+		log.Printf("🚦 累计因限速等待 %v", total.RateWait.Round(time.Second))
+	}
+	if total.MemWait > 0 {
+		_, peak, limit, _ := memBudget.Usage()
+		log.Printf("💾 累计等待内存预算 %v, 在途数据峰值 %.1f / %.1f MB",
+			total.MemWait.Round(time.Second), float64(peak)/(1<<20), float64(limit)/(1<<20))
+	}
+}
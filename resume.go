@@ -0,0 +1,165 @@
+package main
+
+import (
+	"dm2mysql-migrator/checkpoint"
+	"dm2mysql-migrator/database"
+	"log"
+	"math"
+)
+
+// store 本次运行的检查点存储
+var store *checkpoint.Store
+
+// tableCheckpoint 把单张表的导入进度写入检查点
+type tableCheckpoint struct {
+	name string
+	key  string // 续传使用的整数主键列，为空时只记录完成状态，不能按键续传
+}
+
+// chunkStates 把分片计划转换为检查点
+func chunkStates(chunks []database.ChunkRange) []checkpoint.ChunkState {
+	states := make([]checkpoint.ChunkState, len(chunks))
+	for i, c := range chunks {
+		states[i] = checkpoint.ChunkState{Lo: c.Lo, Hi: c.Hi}
+	}
+	return states
+}
+
+// chunkRanges 从检查点恢复分片计划，续传时必须沿用上次的分片边界
+func chunkRanges(st checkpoint.TableState) []database.ChunkRange {
+	chunks := make([]database.ChunkRange, len(st.Chunks))
+	for i, c := range st.Chunks {
+		chunks[i] = database.ChunkRange{Index: i, Column: st.KeyColumn, Lo: c.Lo, Hi: c.Hi}
+	}
+	return chunks
+}
+
+// start 记录一张表开始全新导入
+func (c *tableCheckpoint) start(target, backupName, action string, chunks []database.ChunkRange) {
+	c.save(func(st *checkpoint.TableState) {
+		*st = checkpoint.TableState{
+			Status:    checkpoint.StatusInProgress,
+			Action:    action,
+			Target:    target,
+			Backup:    backupName,
+			KeyColumn: c.key,
+			Chunks:    chunkStates(chunks),
+		}
+	})
+}
+
+// commitTable 整表导入时推进已提交的键值
+func (c *tableCheckpoint) commitTable(lastKey, rows int64) {
+	c.progress(func(st *checkpoint.TableState) {
+		st.LastKey = &lastKey
+		st.Rows += rows
+	})
+}
+
+// commitChunk 返回分片导入时推进已提交键值的回调
+func (c *tableCheckpoint) commitChunk(i int) func(lastKey, rows int64) {
+	return func(lastKey, rows int64) {
+		c.progress(func(st *checkpoint.TableState) {
+			if i < len(st.Chunks) {
+				st.Chunks[i].LastKey = &lastKey
+				st.Chunks[i].Rows += rows
+			}
+			st.Rows += rows
+		})
+	}
+}
+
+// chunkDone 标记分片完成
+func (c *tableCheckpoint) chunkDone(i int) {
+	c.save(func(st *checkpoint.TableState) {
+		if i < len(st.Chunks) {
+			st.Chunks[i].Done = true
+		}
+	})
+}
+
+// setBackup 记录影子表切换时保留的备份表
+func (c *tableCheckpoint) setBackup(name string) {
+	c.save(func(st *checkpoint.TableState) { st.Backup = name })
+}
+
+// complete 标记整张表完成，-resume 时将被跳过
+func (c *tableCheckpoint) complete(rows int64) {
+	c.save(func(st *checkpoint.TableState) {
+		st.Status = checkpoint.StatusCompleted
+		if st.Rows < rows {
+			st.Rows = rows
+		}
+	})
+}
+
+// reset 删除检查点，目标表中的数据已被丢弃时调用
+func (c *tableCheckpoint) reset() {
+	if err := store.Reset(c.name); err != nil {
+		log.Printf("⚠️  更新表 %s 的检查点失败: %v", c.name, err)
+	}
+}
+
+// chunkAfter 返回分片已提交的最大键值
+func (c *tableCheckpoint) chunkAfter(i int) *int64 {
+	st, _ := store.Table(c.name)
+	if i < len(st.Chunks) {
+		return st.Chunks[i].LastKey
+	}
+	return nil
+}
+
+// chunkIsDone 判断分片是否已在上次运行中完成
+func (c *tableCheckpoint) chunkIsDone(i int) bool {
+	st, _ := store.Table(c.name)
+	return i < len(st.Chunks) && st.Chunks[i].Done
+}
+
+func (c *tableCheckpoint) save(fn func(st *checkpoint.TableState)) {
+	if err := store.Update(c.name, fn); err != nil {
+		log.Printf("⚠️  更新表 %s 的检查点失败: %v", c.name, err)
+	}
+}
+
+func (c *tableCheckpoint) progress(fn func(st *checkpoint.TableState)) {
+	if err := store.Progress(c.name, fn); err != nil {
+		log.Printf("⚠️  更新表 %s 的检查点失败: %v", c.name, err)
+	}
+}
+
+// discardUncommitted 续传前删除目标表中检查点之后的行
+// 这些行可能已经写入，但写入后进程退出，没来得及记录到检查点；删除后重新导入可以避免重复
+func discardUncommitted(workerID int, mysql *database.MySQLConnector, st checkpoint.TableState) error {
+	ranges := [][2]int64{}
+	if len(st.Chunks) == 0 {
+		lo := int64(math.MinInt64)
+		if st.LastKey != nil {
+			lo = *st.LastKey + 1
+		}
+		ranges = append(ranges, [2]int64{lo, math.MaxInt64})
+	}
+	for _, c := range st.Chunks {
+		if c.Done {
+			continue
+		}
+		lo := c.Lo
+		if c.LastKey != nil {
+			lo = *c.LastKey + 1
+		}
+		ranges = append(ranges, [2]int64{lo, c.Hi})
+	}
+
+	for _, r := range ranges {
+		if r[0] > r[1] {
+			continue
+		}
+		n, err := mysql.DeleteKeyRange(st.Target, st.KeyColumn, r[0], r[1])
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("[Worker %d] 🧹 表 %s 删除检查点之后未确认的 %d 行", workerID, st.Target, n)
+		}
+	}
+	return nil
+}
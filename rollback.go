@@ -0,0 +1,61 @@
+package main
+
+import (
+	"dm2mysql-migrator/database"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// runRollback 执行 rollback 子命令: 用指定运行产生的备份表恢复原表
+func runRollback(args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	run := fs.String("run", "", "要回滚的运行 ID")
+	fs.Parse(args)
+
+	if *run == "" {
+		fmt.Println("❌ 缺少 -run 参数")
+		fs.Usage()
+		os.Exit(1)
+	}
+	checkMySQLFlags()
+
+	log.Printf("↩️  开始回滚运行 %s ...", *run)
+	mysqlConn, err := database.NewMySQLConnector(buildMySQLDSN(), *mysqlVer, mysqlSessionInit(false)...)
+	if err != nil {
+		log.Fatalf("MySQL连接失败: %v", err)
+	}
+	defer mysqlConn.Close()
+
+	backups, err := mysqlConn.ListRunBackups(*run)
+	if err != nil {
+		log.Fatalf("查询备份表失败: %v", err)
+	}
+	if len(backups) == 0 {
+		log.Printf("⚠️  没有找到运行 %s 的备份表", *run)
+		return
+	}
+
+	tables := make([]string, 0, len(backups))
+	for t := range backups {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	failed := 0
+	for _, t := range tables {
+		if err := mysqlConn.RestoreBackup(t, backups[t]); err != nil {
+			log.Printf("❌ 恢复表 %s 失败: %v", t, err)
+			failed++
+			continue
+		}
+		log.Printf("✅ 表 %s 已从 %s 恢复", t, backups[t])
+	}
+
+	log.Printf("↩️  回滚完成: 恢复 %d 张表, 失败 %d 张", len(tables)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}